@@ -0,0 +1,236 @@
+package diff
+
+/*
+Kind identifies what an Edit does to the sequences being compared
+*/
+type Kind int
+
+const (
+	Equal Kind = iota
+	Delete
+	Insert
+)
+
+/*
+Edit is a run of N elements that are either equal in both sequences,
+deleted from a, or inserted from b. A and B are the indexes in a and b
+at which the run starts; for a Delete, B is where the run would sit in b
+and for an Insert, A is where it would sit in a
+*/
+type Edit struct {
+	Kind Kind
+	A    int
+	B    int
+	N    int
+}
+
+/*
+Compute returns the shortest edit script turning a into b as a list of
+runs, in order. It uses the linear-space variant of Myers' O(ND)
+algorithm, so large inputs with few differences stay cheap
+*/
+func Compute[T comparable](a, b []T) []Edit {
+	d := differ[T]{
+		a:   a,
+		b:   b,
+		del: make([]bool, len(a)),
+		ins: make([]bool, len(b)),
+	}
+	d.compare(0, len(a), 0, len(b))
+
+	return d.edits()
+}
+
+type differ[T comparable] struct {
+	a   []T
+	b   []T
+	del []bool
+	ins []bool
+	vf  []int
+	vb  []int
+}
+
+func (d *differ[T]) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+
+	if aLo == aHi {
+		for y := bLo; y < bHi; y++ {
+			d.ins[y] = true
+		}
+		return
+	}
+	if bLo == bHi {
+		for x := aLo; x < aHi; x++ {
+			d.del[x] = true
+		}
+		return
+	}
+
+	x, y, u, v := d.middleSnake(aLo, aHi, bLo, bHi)
+
+	//	a snake spanning the whole range means no progress can be made by splitting;
+	//	this cannot happen once common ends are trimmed, but guard against looping forever
+	if (x == aLo && y == bLo && u == aHi && v == bHi) || (x == aHi && y == bHi) || (u == aLo && v == bLo) {
+		for i := aLo; i < aHi; i++ {
+			d.del[i] = true
+		}
+		for j := bLo; j < bHi; j++ {
+			d.ins[j] = true
+		}
+		return
+	}
+
+	d.compare(aLo, x, bLo, y)
+	d.compare(u, aHi, v, bHi)
+}
+
+/*
+middleSnake finds the snake in the middle of an optimal path through
+a[aLo:aHi] and b[bLo:bHi], returning its start (x, y) and end (u, v)
+*/
+func (d *differ[T]) middleSnake(aLo, aHi, bLo, bHi int) (int, int, int, int) {
+	n := aHi - aLo
+	m := bHi - bLo
+	delta := n - m
+	odd := delta&1 != 0
+	max := (n + m + 1) / 2
+	off := max + 1
+
+	size := 2*max + 3
+	if cap(d.vf) < size {
+		d.vf = make([]int, size)
+		d.vb = make([]int, size)
+	}
+	vf := d.vf[:size]
+	vb := d.vb[:size]
+	vf[off+1] = 0
+	vb[off+1] = 0
+
+	for D := 0; D <= max; D++ {
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+
+			if odd && k-delta >= -(D-1) && k-delta <= D-1 {
+				if vf[off+k]+vb[off+delta-k] >= n {
+					return aLo + sx, bLo + sy, aLo + x, bLo + y
+				}
+			}
+		}
+
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && vb[off+k-1] < vb[off+k+1]) {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			vb[off+k] = x
+
+			if !odd && delta-k >= -D && delta-k <= D {
+				if vb[off+k]+vf[off+delta-k] >= n {
+					return aHi - x, bHi - y, aHi - sx, bHi - sy
+				}
+			}
+		}
+	}
+
+	//	unreachable for well formed input; treat the whole range as changed
+	return aLo, bLo, aLo, bLo
+}
+
+func (d *differ[T]) edits() []Edit {
+	edits := []Edit{}
+	i, j := 0, 0
+
+	add := func(kind Kind, a, b int) {
+		if l := len(edits) - 1; l >= 0 && edits[l].Kind == kind {
+			edits[l].N++
+			return
+		}
+		edits = append(edits, Edit{Kind: kind, A: a, B: b, N: 1})
+	}
+
+	for i < len(d.a) || j < len(d.b) {
+		if i < len(d.a) && d.del[i] {
+			add(Delete, i, j)
+			i++
+		} else if j < len(d.b) && d.ins[j] {
+			add(Insert, i, j)
+			j++
+		} else {
+			add(Equal, i, j)
+			i++
+			j++
+		}
+	}
+
+	return edits
+}
+
+/*
+Distance returns the number of elements deleted plus the number inserted
+by the edit script
+*/
+func Distance(edits []Edit) int {
+	n := 0
+	for _, e := range edits {
+		if e.Kind != Equal {
+			n += e.N
+		}
+	}
+	return n
+}
+
+/*
+Levenshtein returns the least number of elements that have to be
+inserted, deleted or replaced to turn a into b. Unlike Distance, a
+replaced element counts once, so it suits comparing short sequences such
+as the characters of two words
+*/
+func Levenshtein[T comparable](a, b []T) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		diagonal := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			above := row[j]
+			row[j] = min(row[j]+1, row[j-1]+1, diagonal+cost)
+			diagonal = above
+		}
+	}
+
+	return row[len(b)]
+}
@@ -0,0 +1,170 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func apply(a, b []string, edits []Edit) []string {
+	out := []string{}
+	for _, e := range edits {
+		switch e.Kind {
+		case Equal:
+			out = append(out, a[e.A:e.A+e.N]...)
+		case Insert:
+			out = append(out, b[e.B:e.B+e.N]...)
+		}
+	}
+	return out
+}
+
+func TestCompute(t *testing.T) {
+	var tests = []struct {
+		name     string
+		a        string
+		b        string
+		distance int
+	}{
+		{"identical", "sing goddess the wrath", "sing goddess the wrath", 0},
+		{"both empty", "", "", 0},
+		{"insert into empty", "", "sing goddess", 2},
+		{"delete all", "sing goddess", "", 2},
+		{"typo", "the wrath of Achilles Peleus son", "the wrath of Achillcs Peleus son", 2},
+		{"missing word", "brought on the Achaians woes", "brought on the woes", 1},
+		{"extra words", "of men and noble Achilles", "of men and the noble son Achilles", 2},
+		{"reordered", "a b c d e f", "f e d c b a", 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := strings.Fields(tt.a)
+			b := strings.Fields(tt.b)
+			edits := Compute(a, b)
+
+			if res := apply(a, b, edits); strings.Join(res, " ") != strings.Join(b, " ") {
+				t.Errorf("\ngot:  '%s'\nwant: '%s'", strings.Join(res, " "), tt.b)
+			}
+
+			if d := Distance(edits); d != tt.distance {
+				t.Errorf("got distance %d, want %d", d, tt.distance)
+			}
+		})
+	}
+}
+
+func TestWriteUnified(t *testing.T) {
+	a := []string{"one", "two", "three", "four", "five", "six", "seven", "eight"}
+	b := []string{"one", "two", "3", "four", "five", "six", "seven", "eight", "nine"}
+
+	out := strings.Builder{}
+	if err := WriteUnified(&out, "a", "b", a, b, 1); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	want := "--- a\n+++ b\n" +
+		"@@ -2,3 +2,3 @@\n two\n-three\n+3\n four\n" +
+		"@@ -8 +8,2 @@\n eight\n+nine\n"
+
+	if out.String() != want {
+		t.Errorf("\ngot:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	var tests = []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		want      string
+		conflicts int
+	}{
+		{"only ours changed", "sing goddess the wrath", "sing goddess of the wrath", "sing goddess the wrath", "sing goddess of the wrath", 0},
+		{"only theirs changed", "sing goddess the wrath", "sing goddess the wrath", "sing muse the wrath", "sing muse the wrath", 0},
+		{"separate changes", "the wrath of Achillcs Peleus son that brought woes", "the wrath of Achilles Peleus son that brought woes", "the wrath of Achillcs Peleus son that brought countless woes", "the wrath of Achilles Peleus son that brought countless woes", 0},
+		{"same change", "of men and noble Achillcs", "of men and noble Achilles", "of men and noble Achilles", "of men and noble Achilles", 0},
+		{"conflicting change", "of men and noble Achillcs", "of men and noble Achilles", "of men and noble Achillees", "of men and noble Achilles", 1},
+		{"inserts at the same place", "a b c", "a x b c", "a y b c", "a x b c", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflicts := Merge3(strings.Fields(tt.base), strings.Fields(tt.ours), strings.Fields(tt.theirs))
+
+			if res := strings.Join(merged, " "); res != tt.want {
+				t.Errorf("\ngot:  '%s'\nwant: '%s'", res, tt.want)
+			}
+
+			if len(conflicts) != tt.conflicts {
+				t.Errorf("got %d conflicts, want %d", len(conflicts), tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestMap(t *testing.T) {
+	a := strings.Fields("the wrath of Achilles")
+	b := strings.Fields("sing the wrath Achilles")
+	edits := Compute(a, b)
+
+	//	"of" was deleted, so maps to where it would sit; 4 is past the end of a
+	for i, want := range []int{1, 2, 3, 3, 4} {
+		if got := Map(edits, i); got != want {
+			t.Errorf("Map(%d) = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestParallel(t *testing.T) {
+	a := strings.Fields("sing goddess the wrath of achilles peleus son the ruinous wrath that brought on the achaians woes innumerable and hurled down into hades many strong souls of heroes")
+	b := strings.Fields("sing goddess the wrath of achillcs peleus son the ruinous wrath that brought the achaians woes innumerable and hurled down into hades many brave strong souls of heroes")
+
+	whole := Segment{AEnd: len(a), BEnd: len(b)}
+	segments := Split(a, b, whole, 5)
+	if len(segments) < 3 {
+		t.Fatalf("got %d segments, want the words found once in each to split it up", len(segments))
+	}
+	if segments[0].A != 0 || segments[len(segments)-1].AEnd != len(a) || segments[len(segments)-1].BEnd != len(b) {
+		t.Errorf("segments %v don't cover the whole of a and b", segments)
+	}
+	for k := 1; k < len(segments); k++ {
+		if segments[k].A != segments[k-1].AEnd || segments[k].B != segments[k-1].BEnd {
+			t.Errorf("segments %v don't follow on from each other", segments)
+		}
+	}
+
+	got := []Hunk{}
+	for h := range Parallel(a, b, segments, 4) {
+		got = append(got, h)
+	}
+	want := Hunks(Compute(a, b), 0)
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d hunks, want %d", len(got), len(want))
+	}
+	for k := range want {
+		if got[k].A != want[k].A || got[k].ALen != want[k].ALen || got[k].B != want[k].B || got[k].BLen != want[k].BLen {
+			t.Errorf("hunk %d: got %+v, want %+v", k, got[k], want[k])
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	var tests = []struct {
+		a, b string
+		want int
+	}{
+		{"Achilles", "Achillcs", 1},
+		{"Achilles", "Achiles", 1},
+		{"wrath", "wrath", 0},
+		{"", "sing", 4},
+		{"kitten", "sitting", 3},
+		{"Peleus’", "Peleus'", 1},
+	}
+
+	for _, tt := range tests {
+		if got := Levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package diff
+
+/*
+Conflict is a part of base that ours and theirs both changed, differently.
+Base, Ours and Theirs are where the part starts in each sequence, and
+BaseN, OursN and TheirsN its length in each. Merged is where ours' version
+of the part starts in the merged sequence
+*/
+type Conflict struct {
+	Base    int
+	BaseN   int
+	Ours    int
+	OursN   int
+	Theirs  int
+	TheirsN int
+	Merged  int
+}
+
+/*
+Merge3 applies both the changes made from base to ours and those made from
+base to theirs, returning the merged sequence. Where ours and theirs change
+the same part of base differently, ours' version is kept and the part is
+reported as a Conflict
+*/
+func Merge3[T comparable](base, ours, theirs []T) ([]T, []Conflict) {
+	oursChunks := chunks(Compute(base, ours), true)
+	theirsChunks := chunks(Compute(base, theirs), false)
+
+	merged := []T{}
+	conflicts := []Conflict{}
+
+	//	offsets from an index in base to the same place in ours and theirs,
+	//	as of the chunks applied so far
+	oursShift, theirsShift := 0, 0
+	pos := 0
+
+	for len(oursChunks) > 0 || len(theirsChunks) > 0 {
+		//	start a group at whichever side's next chunk comes first in base,
+		//	then pull in every chunk from either side that overlaps it
+		group := []chunk{}
+		oursN, theirsN := 0, 0
+		start, end := -1, -1
+
+		for {
+			side := &theirsChunks
+			if len(oursChunks) > 0 && (len(theirsChunks) == 0 || oursChunks[0].aLo <= theirsChunks[0].aLo) {
+				side = &oursChunks
+			}
+			if len(*side) == 0 {
+				break
+			}
+
+			c := (*side)[0]
+			if start >= 0 && !overlaps(c, start, end) {
+				break
+			}
+			if start < 0 {
+				start, end = c.aLo, c.aHi
+			}
+			end = max(end, c.aHi)
+
+			group = append(group, c)
+			*side = (*side)[1:]
+			if c.ours {
+				oursN++
+			} else {
+				theirsN++
+			}
+		}
+
+		merged = append(merged, base[pos:start]...)
+
+		oursLo, oursHi := start+oursShift, end+oursShift
+		theirsLo, theirsHi := start+theirsShift, end+theirsShift
+		for _, c := range group {
+			if c.ours {
+				oursHi += (c.bHi - c.bLo) - (c.aHi - c.aLo)
+			} else {
+				theirsHi += (c.bHi - c.bLo) - (c.aHi - c.aLo)
+			}
+		}
+
+		switch {
+		case theirsN == 0:
+			merged = append(merged, ours[oursLo:oursHi]...)
+		case oursN == 0:
+			merged = append(merged, theirs[theirsLo:theirsHi]...)
+		case equal(ours[oursLo:oursHi], theirs[theirsLo:theirsHi]):
+			merged = append(merged, ours[oursLo:oursHi]...)
+		default:
+			conflicts = append(conflicts, Conflict{
+				Base:    start,
+				BaseN:   end - start,
+				Ours:    oursLo,
+				OursN:   oursHi - oursLo,
+				Theirs:  theirsLo,
+				TheirsN: theirsHi - theirsLo,
+				Merged:  len(merged),
+			})
+			merged = append(merged, ours[oursLo:oursHi]...)
+		}
+
+		oursShift = oursHi - end
+		theirsShift = theirsHi - end
+		pos = end
+	}
+
+	merged = append(merged, base[pos:]...)
+
+	return merged, conflicts
+}
+
+/*
+Map returns the index in b of the element at index i in a, under the edit
+script turning a into b. An element that was deleted maps to where it would
+have been in b
+*/
+func Map(edits []Edit, i int) int {
+	for _, e := range edits {
+		switch e.Kind {
+		case Equal:
+			if i < e.A+e.N {
+				return e.B + i - e.A
+			}
+		case Delete:
+			if i < e.A+e.N {
+				return e.B
+			}
+		}
+	}
+
+	//	past the end of a, so past the end of b
+	lenA, lenB := 0, 0
+	for _, e := range edits {
+		if e.Kind != Insert {
+			lenA += e.N
+		}
+		if e.Kind != Delete {
+			lenB += e.N
+		}
+	}
+	return lenB + i - lenA
+}
+
+// chunk is a run of changes, base[aLo:aHi] replaced with side[bLo:bHi]
+type chunk struct {
+	aLo, aHi int
+	bLo, bHi int
+	ours     bool
+}
+
+func chunks(edits []Edit, ours bool) []chunk {
+	cs := []chunk{}
+	open := false
+
+	for _, e := range edits {
+		if e.Kind == Equal {
+			open = false
+			continue
+		}
+		if !open {
+			cs = append(cs, chunk{aLo: e.A, aHi: e.A, bLo: e.B, bHi: e.B, ours: ours})
+			open = true
+		}
+		c := &cs[len(cs)-1]
+		if e.Kind == Delete {
+			c.aHi += e.N
+		} else {
+			c.bHi += e.N
+		}
+	}
+
+	return cs
+}
+
+// a chunk overlaps a group if it starts inside it, or inserts at the same place the group starts
+func overlaps(c chunk, start, end int) bool {
+	return c.aLo < end || c.aLo == start
+}
+
+func equal[T comparable](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
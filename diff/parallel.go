@@ -0,0 +1,117 @@
+package diff
+
+/*
+Segment is a pair of ranges, a[A:AEnd] and b[B:BEnd], that are compared
+on their own, such as the same chapter of two texts
+*/
+type Segment struct {
+	A    int
+	AEnd int
+	B    int
+	BEnd int
+}
+
+/*
+Split divides a segment into smaller ones at elements found exactly once
+in each of its ranges, in the same order in both, so long as each piece
+is at least size elements of a. Such elements can only be each other's
+match, so comparing the pieces on their own gives much the same result
+as comparing the whole segment
+*/
+func Split[T comparable](a, b []T, seg Segment, size int) []Segment {
+	count := func(xs []T) map[T]int {
+		n := map[T]int{}
+		for _, x := range xs {
+			n[x]++
+		}
+		return n
+	}
+	inA := count(a[seg.A:seg.AEnd])
+	inB := count(b[seg.B:seg.BEnd])
+
+	unique := func(xs []T, from int) ([]T, []int) {
+		vals, at := []T{}, []int{}
+		for k, x := range xs {
+			if inA[x] == 1 && inB[x] == 1 {
+				vals = append(vals, x)
+				at = append(at, from+k)
+			}
+		}
+		return vals, at
+	}
+	aVals, aAt := unique(a[seg.A:seg.AEnd], seg.A)
+	bVals, bAt := unique(b[seg.B:seg.BEnd], seg.B)
+
+	segs := []Segment{}
+	cur := Segment{A: seg.A, B: seg.B}
+	for _, e := range Compute(aVals, bVals) {
+		if e.Kind != Equal {
+			continue
+		}
+		for n := 0; n < e.N; n++ {
+			at, bt := aAt[e.A+n], bAt[e.B+n]
+			if at-cur.A >= size && seg.AEnd-at >= size {
+				cur.AEnd, cur.BEnd = at, bt
+				segs = append(segs, cur)
+				cur = Segment{A: at, B: bt}
+			}
+		}
+	}
+	cur.AEnd, cur.BEnd = seg.AEnd, seg.BEnd
+
+	return append(segs, cur)
+}
+
+/*
+Parallel finds the hunks, without context, of each segment on a pool of
+workers goroutines. The hunks are sent on the returned channel in the
+order of the segments, as soon as those before them are done, and the
+channel is closed after the last. Hunk and edit indexes are into a and b
+*/
+func Parallel[T comparable](a, b []T, segments []Segment, workers int) <-chan Hunk {
+	jobs := make(chan int)
+	results := make([]chan []Hunk, len(segments))
+	for k := range results {
+		results[k] = make(chan []Hunk, 1)
+	}
+
+	go func() {
+		for k := range segments {
+			jobs <- k
+		}
+		close(jobs)
+	}()
+
+	for w := 0; w < max(workers, 1); w++ {
+		go func() {
+			for k := range jobs {
+				results[k] <- segmentHunks(a, b, segments[k])
+			}
+		}()
+	}
+
+	out := make(chan Hunk)
+	go func() {
+		for _, result := range results {
+			for _, h := range <-result {
+				out <- h
+			}
+		}
+		close(out)
+	}()
+
+	return out
+}
+
+func segmentHunks[T comparable](a, b []T, seg Segment) []Hunk {
+	hunks := Hunks(Compute(a[seg.A:seg.AEnd], b[seg.B:seg.BEnd]), 0)
+	for h := range hunks {
+		hunks[h].A += seg.A
+		hunks[h].B += seg.B
+		for e := range hunks[h].Edits {
+			hunks[h].Edits[e].A += seg.A
+			hunks[h].Edits[e].B += seg.B
+		}
+	}
+	return hunks
+}
@@ -0,0 +1,124 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+)
+
+/*
+Hunk is a group of edits close enough together to be shown with shared
+context lines
+*/
+type Hunk struct {
+	A     int
+	ALen  int
+	B     int
+	BLen  int
+	Edits []Edit
+}
+
+/*
+Hunks groups an edit script into hunks, keeping up to context equal
+elements around each change
+*/
+func Hunks(edits []Edit, context int) []Hunk {
+	hunks := []Hunk{}
+	var cur *Hunk
+
+	for idx, e := range edits {
+		if e.Kind != Equal {
+			if cur == nil {
+				hunks = append(hunks, Hunk{A: e.A, B: e.B})
+				cur = &hunks[len(hunks)-1]
+			}
+			cur.Edits = append(cur.Edits, e)
+			continue
+		}
+
+		lead := min(e.N, context)
+		trail := min(e.N, context)
+
+		if cur != nil {
+			if idx < len(edits)-1 && e.N <= 2*context {
+				cur.Edits = append(cur.Edits, e)
+				continue
+			}
+			cur.Edits = append(cur.Edits, Edit{Kind: Equal, A: e.A, B: e.B, N: lead})
+			cur = nil
+		}
+
+		if idx < len(edits)-1 {
+			hunks = append(hunks, Hunk{
+				A:     e.A + e.N - trail,
+				B:     e.B + e.N - trail,
+				Edits: []Edit{{Kind: Equal, A: e.A + e.N - trail, B: e.B + e.N - trail, N: trail}},
+			})
+			cur = &hunks[len(hunks)-1]
+			if trail == 0 {
+				cur.Edits = cur.Edits[:0]
+			}
+		}
+	}
+
+	for h := range hunks {
+		for _, e := range hunks[h].Edits {
+			if e.Kind != Insert {
+				hunks[h].ALen += e.N
+			}
+			if e.Kind != Delete {
+				hunks[h].BLen += e.N
+			}
+		}
+	}
+
+	return hunks
+}
+
+/*
+WriteUnified writes the differences between the lines of a and b to w in
+unified diff format
+*/
+func WriteUnified(w io.Writer, nameA, nameB string, a, b []string, context int) error {
+	hunks := Hunks(Compute(a, b), context)
+	if len(hunks) == 0 {
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", nameA, nameB); err != nil {
+		return err
+	}
+
+	for _, h := range hunks {
+		if _, err := fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(h.A, h.ALen), hunkRange(h.B, h.BLen)); err != nil {
+			return err
+		}
+		for _, e := range h.Edits {
+			for n := 0; n < e.N; n++ {
+				var err error
+				switch e.Kind {
+				case Equal:
+					_, err = fmt.Fprintf(w, " %s\n", a[e.A+n])
+				case Delete:
+					_, err = fmt.Fprintf(w, "-%s\n", a[e.A+n])
+				case Insert:
+					_, err = fmt.Fprintf(w, "+%s\n", b[e.B+n])
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
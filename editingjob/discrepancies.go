@@ -0,0 +1,62 @@
+package editingjob
+
+import (
+	"poweredit/diff"
+	"poweredit/textwords"
+	"runtime"
+)
+
+// words of a chapter at least, between the points it is split at to compare its pieces in parallel
+const segmentSize = 2000
+
+// Body returns the range of words of a file that is compared, leaving out any Project Gutenberg
+// header and footer unless the job's boilerplate setting is "compare"
+func (ej *EditingJob) Body(tw *textwords.TextWords) (start, end int) {
+	if ej.Setting("boilerplate") == "compare" {
+		return 0, tw.Len()
+	}
+	start, end, _ = tw.GutenbergBody()
+	return start, end
+}
+
+// Discrepancies compares the bodies of the file under edit and the source, each chapter on its own,
+// and long chapters in pieces between words found once in both files, spread over a pool of
+// workers. The discrepancies are sent as hunks of word indexes into edit and source, in document
+// order, and the channel closed after the last. Only a snapshot of the words is taken before
+// Discrepancies returns, finding the bodies and chapters and comparing them all being done in the
+// background, so edit and source can be changed while the comparison goes on, though the hunks
+// won't follow
+func (ej *EditingJob) Discrepancies(edit, source *textwords.TextWords) <-chan diff.Hunk {
+	edit, source = edit.Snapshot(), source.Snapshot()
+	found := make(chan diff.Hunk)
+
+	go func() {
+		defer close(found)
+
+		editStart, editEnd := ej.Body(edit)
+		sourceStart, sourceEnd := ej.Body(source)
+
+		a := compared(edit, 0, edit.Len())
+		b := compared(source, 0, source.Len())
+
+		//	each chapter is a segment, split further where it is long
+		segments := []diff.Segment{}
+		cur := diff.Segment{A: editStart, B: sourceStart}
+		for _, anchor := range textwords.PairChapters(edit, source) {
+			if anchor.Edit <= cur.A || anchor.Source <= cur.B || anchor.Edit >= editEnd || anchor.Source >= sourceEnd {
+				continue
+			}
+			cur.AEnd, cur.BEnd = anchor.Edit, anchor.Source
+			segments = append(segments, diff.Split(a, b, cur, segmentSize)...)
+			cur = diff.Segment{A: anchor.Edit, B: anchor.Source}
+		}
+		cur.AEnd, cur.BEnd = max(editEnd, cur.A), max(sourceEnd, cur.B)
+		segments = append(segments, diff.Split(a, b, cur, segmentSize)...)
+
+		for h := range diff.Parallel(a, b, segments, runtime.NumCPU()) {
+			found <- h
+		}
+	}()
+
+	return found
+}
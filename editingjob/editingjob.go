@@ -1,35 +1,20 @@
 package editingjob
 
 import (
-	"encoding/csv"
 	"errors"
 	"fmt"
 	"io/fs"
-	"log"
 	"os"
 	"path/filepath"
+	"poweredit/textwords"
 	"poweredit/utils"
-	"strconv"
+	"slices"
 	"strings"
+	"time"
 )
 
-var JOB_DIRECTORY string
-var TEXT_DIRECTORY string
-
-func init() {
-	homedir, exists := os.LookupEnv("HOME")
-	if !exists {
-		log.Fatal("could not locate home directory by environment variable HOME")
-	}
-
-	JOB_DIRECTORY = homedir + "/.powerEdit/jobs"   //	use actual application file
-	TEXT_DIRECTORY = homedir + "/.powerEdit/texts" //	use actual application file
-
-	createFileIfNotExist(JOB_DIRECTORY)
-	createFileIfNotExist(TEXT_DIRECTORY)
-}
-
 type EditingJob struct { // TODO: have only a sinlge LatestEdition field
+	store            *Store
 	name             string
 	editingFile      string // location of txt file
 	sourceFile       string // location of txt file
@@ -38,8 +23,20 @@ type EditingJob struct { // TODO: have only a sinlge LatestEdition field
 	latestEdition    int    // latest edition of txt file
 	LastEditingIndex int    // where to start processing txt file
 	LastSourceIndex  int    // where to start processing txt file
+
+	notes    string
+	settings map[string]string
+	history  []HistoryEntry
+
+	editingSum  string   // SHA-256 of editingFile when the job was created, or its decisions last re-applied
+	sourceSum   string   // SHA-256 of sourceFile when the job was created, or its decisions last re-applied
+	editingBase string   // copy of editingFile the editions derive from, "" for edition 0
+	sourceBase  string   // copy of sourceFile the editions derive from, "" for edition 0
+	changed     []string // originals that no longer match their fingerprint
+	legacy      string   // CSV job file of a job not yet migrated to a manifest
 }
 
+// FieldNameSlice is the header of the CSV job files used before job manifests
 func (ej *EditingJob) FieldNameSlice() []string {
 	return []string{
 		"name",
@@ -53,6 +50,10 @@ func (ej *EditingJob) FieldNameSlice() []string {
 	}
 }
 
+func (ej *EditingJob) Name() string {
+	return ej.name
+}
+
 func (ej *EditingJob) LatestEditFile() string {
 	return ej.latestEditFile
 }
@@ -65,6 +66,7 @@ func (ej *EditingJob) BumpEdition() {
 	ej.latestEdition++
 }
 
+// ToStringSlice is the job as a row of the CSV job files used before job manifests
 func (ej *EditingJob) ToStringSlice() []string {
 	return []string{
 		ej.name,
@@ -78,12 +80,79 @@ func (ej *EditingJob) ToStringSlice() []string {
 	}
 }
 
+func (ej *EditingJob) Notes() string {
+	return ej.notes
+}
+
+func (ej *EditingJob) SetNotes(notes string) {
+	ej.notes = notes
+}
+
+// Setting returns the value of a job setting, or "" if it isn't set
+func (ej *EditingJob) Setting(key string) string {
+	return ej.settings[key]
+}
+
+// SetSetting sets a job setting, an empty value removes it
+func (ej *EditingJob) SetSetting(key, value string) {
+	if value == "" {
+		delete(ej.settings, key)
+		return
+	}
+	if ej.settings == nil {
+		ej.settings = map[string]string{}
+	}
+	ej.settings[key] = value
+}
+
+// Words reads the words of one of the job's files, as the job's settings say to compare them. The
+// punctuation setting "split" makes punctuation at the start and end of words tokens of their own.
+// The markup setting "gutenberg" compares the words under Project Gutenberg's transcriber markup,
+// and "gutenberg-skip" also leaves out everything inside its bracketed notes
+func (ej *EditingJob) Words(filename string) (*textwords.TextWords, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return &textwords.TextWords{}, err
+	}
+	return ej.wordsOf(b, filename)
+}
+
+// wordsOf reads the content of a file named filename as Words does
+func (ej *EditingJob) wordsOf(b []byte, filename string) (*textwords.TextWords, error) {
+	tw, err := textwords.FromBytes(b, filename)
+	if err != nil {
+		return tw, err
+	}
+
+	if ej.Setting("punctuation") == "split" {
+		tw.SplitPunctuation()
+	}
+
+	switch ej.Setting("markup") {
+	case "gutenberg":
+		tw.UseGutenbergMarkup(false)
+	case "gutenberg-skip":
+		tw.UseGutenbergMarkup(true)
+	}
+	return tw, nil
+}
+
+// History lists every change to where the job points, oldest first
+func (ej *EditingJob) History() []HistoryEntry {
+	return ej.history
+}
+
+// editions of a job's files live in the job's own directory, eg. ~/.powerEdit/jobs/edit_badfoo_by_goodfoo/texts
+func (ej *EditingJob) textDirectory() string {
+	return filepath.Join(ej.store.JobDirectory, ej.name, "texts")
+}
+
 func (ej *EditingJob) generateLatestEditFilepath() string {
-	return filepath.Join(TEXT_DIRECTORY, fmt.Sprintf("%d_%s", ej.latestEdition, filepath.Base(ej.editingFile)))
+	return filepath.Join(ej.textDirectory(), fmt.Sprintf("%d_%s", ej.latestEdition, filepath.Base(ej.editingFile)))
 }
 
 func (ej *EditingJob) generateLatestSourceFilepath() string {
-	return filepath.Join(TEXT_DIRECTORY, fmt.Sprintf("%d_%s", ej.latestEdition, filepath.Base(ej.sourceFile)))
+	return filepath.Join(ej.textDirectory(), fmt.Sprintf("%d_%s", ej.latestEdition, filepath.Base(ej.sourceFile)))
 }
 
 func (ej *EditingJob) SaveLatestEditAndSourceChanges(edits, source string) error {
@@ -96,144 +165,154 @@ func (ej *EditingJob) SaveLatestEditAndSourceChanges(edits, source string) error
 	}
 	if err := utils.UpdateFile(newSource, source); err != nil {
 		ej.latestEdition--
-		err := os.Remove(newEdits)
+		os.Remove(newEdits)
 		return fmt.Errorf("error updating %s: %v", newSource, err)
 	}
+	ej.latestEditFile = newEdits
+	ej.latestSourceFile = newSource
 	return nil
 }
 
+// UpdateEditingJob records where the job now points in its history and writes the job manifest
 func (ej *EditingJob) UpdateEditingJob() error {
-	file, err := os.OpenFile(
-		filepath.Join(JOB_DIRECTORY, ej.name, ej.name+".csv"),
-		os.O_APPEND|os.O_WRONLY,
-		os.ModeAppend,
-	)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// Write the job data
-	record := ej.ToStringSlice()
-	err = writer.Write(record)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func FromJobFile(jobfile string) (*EditingJob, error) {
-	base := filepath.Base(jobfile)
-	noext := strings.TrimRight(base, ".csv")
-	return ReadEditingJob(filepath.Join(JOB_DIRECTORY, noext, jobfile))
+	return ej.record(HistorySaved)
 }
 
-func ReadEditingJob(filename string) (*EditingJob, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+// SaveSession saves edits and source as a new edition and records the indexes the session
+// left off at, as one transaction. The edition files are written first, and only become part
+// of the job once the manifest pointing at them is written, so a crash part way through leaves
+// the job at its previous edition. kind is HistorySaved, or HistoryAutosave for a save part way
+// through a session. A save that follows an autosave takes the autosave's place, in the history
+// and on disk, so autosaving leaves no more editions behind than saving at the end would
+func (ej *EditingJob) SaveSession(edits, source string, editingIndex, sourceIndex int, kind string) error {
+	previous := *ej
+
+	autosave, folded := ej.autosave()
+	if folded {
+		ej.history = slices.Clone(ej.history[:len(ej.history)-1])
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
+	if err := ej.SaveLatestEditAndSourceChanges(edits, source); err != nil {
+		*ej = previous
+		return err
 	}
 
-	if len(records) < 2 {
-		return nil, fmt.Errorf("CSV file must have at least one record")
-	}
+	ej.LastEditingIndex = editingIndex
+	ej.LastSourceIndex = sourceIndex
 
-	headers := records[0]
+	if err := ej.record(kind); err != nil {
+		os.Remove(ej.latestEditFile)
+		os.Remove(ej.latestSourceFile)
+		*ej = previous
+		return err
+	}
 
-	if headers[0] != "name" || headers[1] != "editing_file" || headers[2] != "source_file" || headers[3] != "latest_edit_file" ||
-		headers[4] != "latest_source_file" || headers[5] != "latest_edition" || headers[6] != "last_editing_index" || headers[7] != "last_source_index" {
-		return nil, fmt.Errorf("unexpected CSV headers for job file: %s\nconsider reviewing file", filename)
+	if folded {
+		os.Remove(autosave.EditFile)
+		os.Remove(autosave.SourceFile)
 	}
 
-	row := records[len(records)-1]
+	return nil
+}
 
-	latestEdition, err := strconv.Atoi(row[5])
-	if err != nil {
-		return nil, err
+// autosave returns the history entry of the autosave the job is at, if it is at one
+func (ej *EditingJob) autosave() (HistoryEntry, bool) {
+	if len(ej.history) == 0 {
+		return HistoryEntry{}, false
 	}
 
-	lastEditingIndex, err := strconv.Atoi(row[6])
-	if err != nil {
-		return nil, err
-	}
+	last := ej.history[len(ej.history)-1]
+	return last, last.Kind == HistoryAutosave && last.EditFile == ej.latestEditFile
+}
 
-	lastSourceIndex, err := strconv.Atoi(row[7])
+func (ej *EditingJob) record(kind string) error {
+	edition, err := editionNumber(ej.latestEditFile)
 	if err != nil {
-		return nil, err
+		edition = ej.latestEdition
 	}
 
-	job := &EditingJob{
-		name:             row[0],
-		editingFile:      row[1],
-		sourceFile:       row[2],
-		latestEditFile:   row[3],
-		latestSourceFile: row[4],
-		latestEdition:    latestEdition,
-		LastEditingIndex: lastEditingIndex,
-		LastSourceIndex:  lastSourceIndex,
+	ej.history = append(ej.history, HistoryEntry{
+		Kind:             kind,
+		Edition:          edition,
+		EditFile:         ej.latestEditFile,
+		SourceFile:       ej.latestSourceFile,
+		LastEditingIndex: ej.LastEditingIndex,
+		LastSourceIndex:  ej.LastSourceIndex,
+		Time:             time.Now(),
+	})
+
+	if err := ej.writeManifest(); err != nil {
+		ej.history = ej.history[:len(ej.history)-1]
+		return err
 	}
 
-	return job, nil
+	return nil
 }
 
-func writeNewEditingJob(filename string, job *EditingJob) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("couldn't create %s: %v", filename, err)
-	}
-	defer file.Close()
+// FromJobFile reads a job by the name of its manifest or its CSV job file. A job that
+// only has a CSV job file is read from it as it is, and migrated to a manifest once it is
+// locked, keeping the CSV as <name>.csv.bak, so reading a job never changes it
+func (s *Store) FromJobFile(jobfile string) (*EditingJob, error) {
+	base := filepath.Base(jobfile)
+	name := strings.TrimSuffix(strings.TrimSuffix(base, ".csv"), ".json")
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	manifest := filepath.Join(s.JobDirectory, name, name+".json")
+	if _, err := os.Stat(manifest); err == nil {
+		job, err := s.ReadEditingJob(manifest)
+		if err != nil {
+			return nil, err
+		}
+		job.checkOriginals()
+		return job, nil
+	}
 
-	// Write the header
-	headers := job.FieldNameSlice()
-	err = writer.Write(headers)
-	if err != nil {
-		return err
+	legacy := filepath.Join(s.JobDirectory, name, name+".csv")
+	if _, err := os.Stat(legacy); err != nil {
+		return nil, fmt.Errorf("no manifest or CSV job file for job %s: %v", name, err)
 	}
 
-	// Write the job data
-	record := job.ToStringSlice()
-	err = writer.Write(record)
+	job, err := s.readLegacyJobFile(legacy)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return nil
+	job.legacy = legacy
+	job.checkOriginals()
+	return job, nil
 }
 
-func FromEditAndSourceFiles(editFile, srceFile string) (*EditingJob, error) {
+func (s *Store) FromEditAndSourceFiles(editFile, srceFile string) (*EditingJob, error) {
 	baseEditName := filepath.Base(editFile)
 	baseSrceName := filepath.Base(srceFile)
-	shortEditFileName := strings.TrimRight(baseEditName, filepath.Ext(editFile))
-	shortSrceFileName := strings.TrimRight(baseSrceName, filepath.Ext(srceFile))
+	shortEditFileName := strings.TrimSuffix(baseEditName, filepath.Ext(editFile))
+	shortSrceFileName := strings.TrimSuffix(baseSrceName, filepath.Ext(srceFile))
 	jobname := strings.TrimSpace(fmt.Sprintf("edit_%s_by_%s", shortEditFileName, shortSrceFileName))
 
 	newJob := EditingJob{
+		store:            s,
 		name:             jobname,
 		editingFile:      editFile,
 		sourceFile:       srceFile,
-		latestEditFile:   filepath.Join(TEXT_DIRECTORY, "0_"+baseEditName),
-		latestSourceFile: filepath.Join(TEXT_DIRECTORY, "0_"+baseSrceName),
 		latestEdition:    0,
 		LastEditingIndex: 0,
 		LastSourceIndex:  0,
 	}
+	newJob.latestEditFile = filepath.Join(newJob.textDirectory(), "0_"+baseEditName)
+	newJob.latestSourceFile = filepath.Join(newJob.textDirectory(), "0_"+baseSrceName)
+
+	//  claim the job's directory first, so an existing job is never overwritten by a new one
+	if err := createFileIfNotExist(s.JobDirectory); err != nil {
+		return nil, err
+	}
+	jobDirectory := filepath.Join(s.JobDirectory, jobname)
+	if err := os.Mkdir(jobDirectory, os.ModePerm); errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("job %s already exists, resume it with `poweredit %s`", jobname, jobname)
+	} else if err != nil {
+		return nil, fmt.Errorf("couldn't create job directory %s: %v", jobDirectory, err)
+	}
 
 	err := writeAllJobFiles(&newJob)
 	if err != nil {
+		os.RemoveAll(jobDirectory)
 		return nil,
 			fmt.Errorf("couldn't create new editing job from %s and %s: %v", editFile, srceFile, err)
 	}
@@ -243,14 +322,8 @@ func FromEditAndSourceFiles(editFile, srceFile string) (*EditingJob, error) {
 
 func writeAllJobFiles(job *EditingJob) error {
 
-	//  create csv job filename eg. ~/.powerEdit/jobs/edit_badfoo_by_goodfoo/edit_badfoo_by_goodfoo.csv
-	newJobfileName := filepath.Join(JOB_DIRECTORY, job.name, job.name+".csv")
-
-	createFileIfNotExist(filepath.Join(JOB_DIRECTORY, job.name))
-
-	//  write job csv
-	if err := writeNewEditingJob(newJobfileName, job); err != nil {
-		return fmt.Errorf("failed to create new csv jobfile: %v", err)
+	if err := createFileIfNotExist(job.textDirectory()); err != nil {
+		return err
 	}
 
 	//  read the original edit and source files
@@ -264,57 +337,25 @@ func writeAllJobFiles(job *EditingJob) error {
 		return fmt.Errorf("couldn't read SourceFile while creating new edit job: %v", err)
 	}
 
+	//  fingerprint the originals, to tell when they change upstream
+	job.editingSum = sum(editFileContent)
+	job.sourceSum = sum(sourceFileContent)
+
 	//  write the first editing version file for the file to edit
 	//      will be used for first edit session, wherefrom edits will be saved to v_1. So v_0 also serves as backup for originals
-	if err := os.WriteFile(job.latestEditFile, editFileContent, 0644); err != nil {
+	if err := utils.UpdateFile(job.latestEditFile, string(editFileContent)); err != nil {
 		return fmt.Errorf("couldn't write version_0 edit file: %v", err)
 	}
 
 	//  write the first editing version file for the source of edits
 	//      will be used for first edit session, wherefrom edits will be saved to v_1. So v_0 also serves as backup for originals
-	if err := os.WriteFile(job.latestSourceFile, sourceFileContent, 0644); err != nil {
+	if err := utils.UpdateFile(job.latestSourceFile, string(sourceFileContent)); err != nil {
 		return fmt.Errorf("couldn't write version_0 source file: %v", err)
 	}
 
-	return nil
-}
-
-func getAllJobs() ([]fs.DirEntry, error) {
-	files, err := os.ReadDir(JOB_DIRECTORY)
-	if err != nil {
-		return nil, fmt.Errorf("could not get jobs: %v", err)
-	}
-
-	return files, nil
-}
-
-func JobExists(jobname string) (bool, error) {
-	jobs, err := getAllJobs()
-	if err != nil {
-		return false, fmt.Errorf("could not display jobs: %v", err)
-	}
-
-	for _, job := range jobs {
-		if job.IsDir() {
-			if jobname == job.Name() {
-				return true, nil
-			}
-		}
-	}
-
-	return false, nil
-}
-
-func DisplayJobs() error {
-	files, err := getAllJobs()
-	if err != nil {
-		return fmt.Errorf("could not display jobs: %v", err)
-	}
-
-	for _, file := range files {
-		if file.IsDir() {
-			fmt.Println(file.Name())
-		}
+	//  write the job manifest eg. ~/.powerEdit/jobs/edit_badfoo_by_goodfoo/edit_badfoo_by_goodfoo.json
+	if err := job.record(HistoryCreated); err != nil {
+		return fmt.Errorf("failed to create new job manifest: %v", err)
 	}
 
 	return nil
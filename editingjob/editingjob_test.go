@@ -1,11 +1,16 @@
 package editingjob
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path"
+	"reflect"
 	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var (
@@ -21,14 +26,15 @@ var (
 	test_newjob_name = "edit_gutn_by_iarc"
 	test_newjob_editingFile = "test/testpowereditdir/testtexts/gutn.txt"
 	test_newjob_sourceFile = "test/testpowereditdir/testtexts/iarc.txt"
-	test_newjob_latestEditFile = "test/testpowereditdir/testtexts/0_gutn.txt"
-	test_newjob_latestSourceFile = "test/testpowereditdir/testtexts/0_iarc.txt"
+	test_newjob_latestEditFile = "test/testpowereditdir/testjobs/edit_gutn_by_iarc/texts/0_gutn.txt"
+	test_newjob_latestSourceFile = "test/testpowereditdir/testjobs/edit_gutn_by_iarc/texts/0_iarc.txt"
 	test_newjob_latestEdition = 0
 	test_newjob_lastEditingIndex = 0
 	test_newjob_lastSourceIndex = 0
 
 
 	mockExistingEditingJob = EditingJob {
+		store: testStore,
 		name: test_name,
 		editingFile: test_editingFile,
 		sourceFile: test_sourceFile,
@@ -40,6 +46,7 @@ var (
 	}
 
 	mockNewEditingJob = EditingJob {
+		store: testStore,
 		name: test_newjob_name,
 		editingFile: test_newjob_editingFile,
 		sourceFile: test_newjob_sourceFile,
@@ -54,6 +61,8 @@ var (
 	TEST_TEXT_DIRECTORY = "test/testpowereditdir/testtexts"
 	TEST_JOBFILE = "edit_gutenberg-iliad_by_ia-iliad.csv"
 
+	testStore = &Store{JobDirectory: TEST_JOB_DIRECTORY, TextDirectory: TEST_TEXT_DIRECTORY}
+
 	TEST_EDIT_FILE_BASE_NOEXT = "gutenberg-iliad"
 	TEST_SOURCE_FILE_BASE_NOEXT = "ia-iliad"
 	TEST_EDIT_FILE_BASE = TEST_EDIT_FILE_BASE_NOEXT+".txt"
@@ -164,34 +173,78 @@ func TestBumpEdition(t *testing.T) {
 }
 
 func TestFromJobFile(t *testing.T) {
-	JOB_DIRECTORY = TEST_JOB_DIRECTORY
-	TEXT_DIRECTORY = TEST_TEXT_DIRECTORY
+	store := copyTestJob(t)
 
-	res, err := FromJobFile(TEST_JOBFILE)
+	res, err := store.FromJobFile(TEST_JOBFILE)
 	if err != nil {
-		t.Errorf("test resulted in error: %v", err)
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	csvFile := path.Join(store.JobDirectory, test_name, test_name+".csv")
+
+	want := mockExistingEditingJob
+	want.store = store
+	want.history = res.history
+	want.legacy = csvFile
+
+	if !reflect.DeepEqual(*res, want) {
+		t.Errorf("\ngot:  %#v, \nwant: %#v\n", *res, want)
+	}
+
+	if len(res.History()) != 6 {
+		t.Errorf("got %d history entries, want one for each of the 6 CSV rows", len(res.History()))
+	}
+
+	//	reading the job leaves it as it is, it is only migrated once it is locked
+	if _, err := os.Stat(csvFile); err != nil {
+		t.Errorf("CSV job file was migrated by reading the job: %v", err)
+	}
+	if _, err := os.Stat(path.Join(store.JobDirectory, test_name, test_name+".json")); err == nil {
+		t.Errorf("manifest was written by reading the job")
+	}
+
+	stale, err := store.FromJobFile(TEST_JOBFILE)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	lock, err := res.Lock()
+	if err != nil {
+		t.Fatalf("locking the job resulted in error: %v", err)
+	}
+
+	if _, err := os.Stat(csvFile + ".bak"); err != nil {
+		t.Errorf("CSV job file was not kept after migration: %v", err)
 	}
+	lock.Release()
 
-	if *res != mockExistingEditingJob {
-		t.Errorf("\ngot:  %#v, \nwant: %#v\n", *res, mockExistingEditingJob)
+	//	a session that read the CSV job file before another migrated it has to read the job again
+	if lock, err := stale.Lock(); err == nil {
+		lock.Release()
+		t.Errorf("locked a job read from a CSV job file that has since been migrated")
+	}
+
+	manifest, err := store.FromJobFile(test_name + ".json")
+	if err != nil {
+		t.Fatalf("reading migrated manifest resulted in error: %v", err)
+	}
+
+	if !reflect.DeepEqual(manifest, res) {
+		t.Errorf("\ngot:  %#v, \nwant: %#v\n", *manifest, *res)
 	}
 }
 
 func TestFromEditAndSourceFiles(t *testing.T) {
-	JOB_DIRECTORY = TEST_JOB_DIRECTORY
-	TEXT_DIRECTORY = TEST_TEXT_DIRECTORY
+	newJobDir := path.Join(testStore.JobDirectory, test_newjob_name)
+	newJobFilePath := path.Join(newJobDir, test_newjob_name+".json")
 
-	newJobDir := path.Join(JOB_DIRECTORY, test_newjob_name)
-	newJobFilePath := path.Join(newJobDir, test_newjob_name+".csv")
-
-	deleteFile(newJobFilePath)
-	deleteFile(newJobDir)
+	os.RemoveAll(newJobDir)
 
 	fullPathEditFile := path.Join(TEST_TEXT_DIRECTORY,TEST_NEWJOB_EDIT_FILE_BASE)
 	fullPathSourceFile := path.Join(TEST_TEXT_DIRECTORY,TEST_NEWJOB_SOURCE_FILE_BASE)
 
 
-	res, err := FromEditAndSourceFiles(fullPathEditFile, fullPathSourceFile)
+	res, err := testStore.FromEditAndSourceFiles(fullPathEditFile, fullPathSourceFile)
 	if err != nil {
 		t.Errorf("test resulted in error: %v", err)
 	}
@@ -200,19 +253,651 @@ func TestFromEditAndSourceFiles(t *testing.T) {
 		t.Errorf("new jobfile %s was not created", newJobFilePath)
 	}
 
-	if !deleteFile(newJobDir) {
+	if !deleteFile(test_newjob_latestEditFile) || !deleteFile(test_newjob_latestSourceFile) {
+		t.Errorf("edition 0 files were not created in %s", path.Join(newJobDir, "texts"))
+	}
+
+	if !deleteFile(path.Join(newJobDir, "texts")) || !deleteFile(newJobDir) {
 		t.Errorf("new job dir %s was not created", newJobDir)
 	}
 
-	if *res != mockNewEditingJob {
-		t.Errorf("\ngot:  %#v\n, \nwant: %#v\n", *res, mockNewEditingJob)
+	if len(res.History()) != 1 || res.History()[0].Kind != HistoryCreated || res.History()[0].Edition != 0 {
+		t.Errorf("new job should have one history entry for its creation, got %#v", res.History())
+	}
+
+	want := mockNewEditingJob
+	want.history = res.history
+	want.editingSum, _ = fileSum(fullPathEditFile)
+	want.sourceSum, _ = fileSum(fullPathSourceFile)
+
+	if !reflect.DeepEqual(*res, want) {
+		t.Errorf("\ngot:  %#v\n, \nwant: %#v\n", *res, want)
+	}
+}
+
+func TestFromEditAndSourceFilesExisting(t *testing.T) {
+	store := &Store{JobDirectory: t.TempDir()}
+	editFile := path.Join(TEST_TEXT_DIRECTORY, TEST_NEWJOB_EDIT_FILE_BASE)
+	sourceFile := path.Join(TEST_TEXT_DIRECTORY, TEST_NEWJOB_SOURCE_FILE_BASE)
+
+	job, err := store.FromEditAndSourceFiles(editFile, sourceFile)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+	if err := job.SaveSession("Sing, goddess", "Sing, O goddess", 2, 3, HistorySaved); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	//	creating the job again must leave the existing one as it was
+	if _, err := store.FromEditAndSourceFiles(editFile, sourceFile); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("creating an existing job should fail, got: %v", err)
+	}
+
+	existing, err := store.FromJobFile(test_newjob_name + ".json")
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+	if existing.latestEdition != 1 || len(existing.History()) != 2 {
+		t.Errorf("existing job was overwritten: edition %d, history %v", existing.latestEdition, existing.History())
+	}
+
+	//	a job that couldn't be created leaves nothing behind, so it can be created once the files are there
+	missing := path.Join(t.TempDir(), "missing.txt")
+	if _, err := store.FromEditAndSourceFiles(missing, sourceFile); err == nil {
+		t.Errorf("creating a job from a missing file should fail")
+	}
+	if exists(path.Join(store.JobDirectory, "edit_missing_by_iarc")) {
+		t.Errorf("failed job left its directory behind")
 	}
 }
 
+// copyTestJob copies the test job's CSV job file into a store in a temporary directory,
+// so tests can migrate and update it
+func copyTestJob(t *testing.T) *Store {
+	store := &Store{JobDirectory: t.TempDir(), TextDirectory: TEST_TEXT_DIRECTORY}
+
+	jobfile, err := os.ReadFile(path.Join(TEST_JOB_DIRECTORY, test_name, TEST_JOBFILE))
+	if err != nil {
+		t.Fatalf("couldn't read test job: %v", err)
+	}
+	os.Mkdir(path.Join(store.JobDirectory, test_name), os.ModePerm)
+	if err := os.WriteFile(path.Join(store.JobDirectory, test_name, TEST_JOBFILE), jobfile, 0644); err != nil {
+		t.Fatalf("couldn't copy test job: %v", err)
+	}
+
+	return store
+}
+
 func deleteFile(filename string) bool {
     err := os.Remove(filename)
     if os.IsNotExist(err) {
         return false
     }
     return err == nil
-}
\ No newline at end of file
+}
+
+func TestEditions(t *testing.T) {
+	job, err := copyTestJob(t).FromJobFile(TEST_JOBFILE)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	res, err := job.Editions()
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	numbers := []int{}
+	for _, edition := range res {
+		numbers = append(numbers, edition.Number)
+		if edition.Saved.IsZero() {
+			t.Errorf("edition %d has no timestamp", edition.Number)
+		}
+	}
+
+	expect := []int{1, 2, 3, 4, 5}
+	if !slices.Equal(numbers, expect) {
+		t.Errorf("got: %v, want: %v", numbers, expect)
+	}
+
+	if res[4].EditFile != test_latestEditFile || res[4].EditingIndex != test_LastEditingIndex || res[4].SourceIndex != test_LastSourceIndex {
+		t.Errorf("\ngot:  %#v\nwant edition 5 of %#v", res[4], mockExistingEditingJob)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	store := copyTestJob(t)
+
+	job, err := store.FromJobFile(TEST_JOBFILE)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	if err := job.Rollback(3); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	res, err := store.FromJobFile(TEST_JOBFILE)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	if res.LatestEditFile() != "test/testpowereditdir/testtexts/3_gutenberg-iliad.txt" ||
+		res.LatestSrceFile() != "test/testpowereditdir/testtexts/3_ia-iliad.txt" {
+		t.Errorf("rolled back job points at %s and %s", res.LatestEditFile(), res.LatestSrceFile())
+	}
+
+	if res.LastEditingIndex != 21717 || res.LastSourceIndex != 21653 {
+		t.Errorf("got indexes [%d %d], want [21717 21653]", res.LastEditingIndex, res.LastSourceIndex)
+	}
+
+	if res.latestEdition != test_latestEdition {
+		t.Errorf("rollback changed latest edition to %d, want %d", res.latestEdition, test_latestEdition)
+	}
+
+	if _, err := res.Edition(6); err == nil {
+		t.Error("rollback should not create a new edition")
+	}
+}
+
+func TestMigrateTexts(t *testing.T) {
+	store := copyTestJob(t)
+
+	job, err := store.FromJobFile(TEST_JOBFILE)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	if shared, err := job.UsesSharedTexts(); err != nil || !shared {
+		t.Fatalf("expected job to use shared texts, got %v, %v", shared, err)
+	}
+
+	copied, err := job.MigrateTexts()
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	if copied != 10 {
+		t.Errorf("got %d files copied, want 10", copied)
+	}
+
+	if shared, err := job.UsesSharedTexts(); err != nil || shared {
+		t.Errorf("expected job to no longer use shared texts, got %v, %v", shared, err)
+	}
+
+	res, err := store.FromJobFile(TEST_JOBFILE)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	expect := path.Join(store.JobDirectory, test_name, "texts", "5_gutenberg-iliad.txt")
+	if res.LatestEditFile() != expect || job.LatestEditFile() != expect {
+		t.Errorf("got: %s, want: %s", res.LatestEditFile(), expect)
+	}
+
+	if same, err := sameContent(test_latestEditFile, expect); err != nil || !same {
+		t.Errorf("migrated %s doesn't match %s", expect, test_latestEditFile)
+	}
+
+	if _, err := job.MigrateTexts(); err != nil {
+		t.Errorf("migrating twice resulted in error: %v", err)
+	}
+}
+
+func TestMigrateAllTexts(t *testing.T) {
+	store := copyTestJob(t)
+
+	//	a job that can't be read, sorted before the test job, and one that is locked, sorted after it
+	os.Mkdir(path.Join(store.JobDirectory, "edit_a_by_b"), os.ModePerm)
+	locked, err := store.FromEditAndSourceFiles(path.Join(TEST_TEXT_DIRECTORY, TEST_NEWJOB_EDIT_FILE_BASE), path.Join(TEST_TEXT_DIRECTORY, TEST_NEWJOB_SOURCE_FILE_BASE))
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+	lock, err := locked.Lock()
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+	defer lock.Release()
+
+	err = store.MigrateAllTexts()
+	if err == nil || !strings.Contains(err.Error(), "edit_a_by_b") || !strings.Contains(err.Error(), test_newjob_name) {
+		t.Errorf("errors of both jobs should be reported, got: %v", err)
+	}
+
+	job, err := store.FromJobFile(TEST_JOBFILE)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+	if shared, err := job.UsesSharedTexts(); err != nil || shared {
+		t.Errorf("jobs after one that failed should still be migrated, got %v, %v", shared, err)
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	root := path.Join(t.TempDir(), "poweredit")
+
+	store, err := NewStore(root)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	if store.JobDirectory != path.Join(root, "jobs") || store.TextDirectory != path.Join(root, "texts") {
+		t.Errorf("got: %#v", *store)
+	}
+
+	if info, err := os.Stat(store.JobDirectory); err != nil || !info.IsDir() {
+		t.Errorf("job directory %s was not created", store.JobDirectory)
+	}
+}
+
+func TestResolveRoot(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("POWEREDIT_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+
+	check := func(name, root, want string) {
+		t.Helper()
+		res, err := ResolveRoot(root)
+		if err != nil {
+			t.Errorf("%s: test resulted in error: %v", name, err)
+		} else if res != want {
+			t.Errorf("%s: got: %s, want: %s", name, res, want)
+		}
+	}
+
+	check("default", "", path.Join(home, ".local", "share", "poweredit"))
+
+	os.Mkdir(path.Join(home, ".powerEdit"), os.ModePerm)
+	check("legacy", "", path.Join(home, ".powerEdit"))
+
+	t.Setenv("XDG_DATA_HOME", path.Join(home, "data"))
+	check("legacy over empty xdg", "", path.Join(home, ".powerEdit"))
+
+	os.MkdirAll(path.Join(home, "data", "poweredit"), os.ModePerm)
+	check("xdg", "", path.Join(home, "data", "poweredit"))
+
+	t.Setenv("POWEREDIT_HOME", path.Join(home, "env"))
+	check("environment", "", path.Join(home, "env"))
+
+	check("flag", path.Join(home, "flag"), path.Join(home, "flag"))
+}
+
+func TestManifestSchemaVersion(t *testing.T) {
+	store := copyTestJob(t)
+
+	job, err := store.FromJobFile(TEST_JOBFILE)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	job.SetNotes("books I-XII checked against the 1898 printing")
+	job.SetSetting("rewrap", "70")
+	if err := job.SaveManifest(); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	res, err := store.FromJobFile(test_name)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	if res.Notes() != job.Notes() || res.Setting("rewrap") != "70" {
+		t.Errorf("notes and settings were not kept, got %q and %q", res.Notes(), res.Setting("rewrap"))
+	}
+
+	manifest := path.Join(store.JobDirectory, test_name, test_name+".json")
+	content, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	newer := strings.Replace(string(content), fmt.Sprintf(`"schema_version": %d`, SchemaVersion), fmt.Sprintf(`"schema_version": %d`, SchemaVersion+1), 1)
+	if err := os.WriteFile(manifest, []byte(newer), 0644); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	if _, err := store.FromJobFile(test_name); err == nil {
+		t.Error("a manifest with a newer schema version should not be read")
+	}
+}
+
+func TestLock(t *testing.T) {
+	store := copyTestJob(t)
+
+	job, err := store.FromJobFile(TEST_JOBFILE)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	lock, err := job.Lock()
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	var locked *LockedError
+	if _, err := job.Lock(); !errors.As(err, &locked) {
+		t.Errorf("locking a locked job should result in a LockedError, got: %v", err)
+	} else if locked.Holder.PID != os.Getpid() {
+		t.Errorf("got holder pid %d, want %d", locked.Holder.PID, os.Getpid())
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	if holder, err := job.LockHolder(); err != nil || holder != nil {
+		t.Errorf("released job should not be locked, got %#v, %v", holder, err)
+	}
+
+	//	a lock left by a process that has gone away is taken over
+	host, _ := os.Hostname()
+	stale := fmt.Sprintf(`{"pid": %d, "host": %q, "started": "2024-01-02T15:04:05Z"}`, 1<<30, host)
+	if err := os.WriteFile(job.lockPath(), []byte(stale), 0644); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	lock, err = job.Lock()
+	if err != nil {
+		t.Fatalf("stale lock was not taken over: %v", err)
+	}
+	lock.Release()
+
+	//	a lock that can't be read may still be being written, so is only taken over once it is old
+	if err := os.WriteFile(job.lockPath(), nil, 0644); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	if _, err := job.Lock(); !errors.As(err, &locked) {
+		t.Errorf("lock being written should not be taken over, got: %v", err)
+	}
+
+	old := time.Now().Add(-2 * lockGrace)
+	if err := os.Chtimes(job.lockPath(), old, old); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	lock, err = job.Lock()
+	if err != nil {
+		t.Fatalf("old unreadable lock was not taken over: %v", err)
+	}
+	lock.Release()
+
+	//	a lock from another host can't be checked, so is kept
+	other := fmt.Sprintf(`{"pid": %d, "host": "another-%s", "started": "2024-01-02T15:04:05Z"}`, 1<<30, host)
+	if err := os.WriteFile(job.lockPath(), []byte(other), 0644); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	if _, err := job.Lock(); !errors.As(err, &locked) {
+		t.Errorf("lock from another host should not be taken over, got: %v", err)
+	}
+
+	if err := job.Unlock(); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	if _, err := job.Lock(); err != nil {
+		t.Errorf("unlocked job could not be locked: %v", err)
+	}
+}
+
+func TestLockRace(t *testing.T) {
+	store := &Store{JobDirectory: t.TempDir()}
+	job, err := store.FromEditAndSourceFiles(path.Join(TEST_TEXT_DIRECTORY, TEST_NEWJOB_EDIT_FILE_BASE), path.Join(TEST_TEXT_DIRECTORY, TEST_NEWJOB_SOURCE_FILE_BASE))
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	//	sessions starting together must never read each other's lock before it is written
+	for round := 0; round < 20; round++ {
+		locks := make(chan *Lock, 8)
+		var wg sync.WaitGroup
+		for n := 0; n < cap(locks); n++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if lock, err := job.Lock(); err == nil {
+					locks <- lock
+				}
+			}()
+		}
+		wg.Wait()
+		close(locks)
+
+		if len(locks) != 1 {
+			t.Fatalf("%d sessions took the lock at once", len(locks))
+		}
+		(<-locks).Release()
+	}
+
+	entries, _ := os.ReadDir(path.Join(store.JobDirectory, test_newjob_name))
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".lock") {
+			t.Errorf("locking left %s behind", entry.Name())
+		}
+	}
+}
+
+func TestSaveSession(t *testing.T) {
+	store := &Store{JobDirectory: t.TempDir()}
+
+	job, err := store.FromEditAndSourceFiles(path.Join(TEST_TEXT_DIRECTORY, TEST_NEWJOB_EDIT_FILE_BASE), path.Join(TEST_TEXT_DIRECTORY, TEST_NEWJOB_SOURCE_FILE_BASE))
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	if err := job.SaveSession("edited", "source", 12, 10, HistoryAutosave); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	res, err := store.FromJobFile(test_newjob_name)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	last := res.History()[len(res.History())-1]
+	if res.LastEditingIndex != 12 || res.LastSourceIndex != 10 || last.Kind != HistoryAutosave || last.Edition != 1 {
+		t.Errorf("saved session was not recorded, got %#v", last)
+	}
+
+	if content, err := os.ReadFile(res.LatestEditFile()); err != nil || string(content) != "edited" {
+		t.Errorf("edition 1 edit file was not written: %v", err)
+	}
+
+	//	a manifest that can't be replaced must leave the job at its previous edition
+	manifest := job.manifestPath()
+	os.Rename(manifest, manifest+".moved")
+	os.MkdirAll(path.Join(manifest, "blocked"), os.ModePerm)
+
+	if err := job.SaveSession("edited again", "source again", 20, 18, HistorySaved); err == nil {
+		t.Fatal("saving over a blocked manifest should fail")
+	}
+
+	if job.latestEdition != 1 || job.LastEditingIndex != 12 || len(job.History()) != 2 {
+		t.Errorf("failed save changed the job: %#v", *job)
+	}
+
+	if _, err := os.Stat(job.generateLatestEditFilepath()); err != nil {
+		t.Errorf("edition 1 should still exist: %v", err)
+	}
+
+	job.latestEdition++
+	if _, err := os.Stat(job.generateLatestEditFilepath()); err == nil {
+		t.Error("edition files of a failed save should be removed")
+	}
+	job.latestEdition--
+
+	//	later saves take the place of the autosave, leaving one edition for the session
+	os.RemoveAll(manifest)
+	os.Rename(manifest+".moved", manifest)
+	autosaved := []string{job.LatestEditFile(), job.LatestSrceFile()}
+
+	if err := job.SaveSession("edited again", "source again", 20, 18, HistoryAutosave); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+	autosaved = append(autosaved, job.LatestEditFile(), job.LatestSrceFile())
+
+	if err := job.SaveSession("edited at last", "source at last", 30, 28, HistorySaved); err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	res, err = store.FromJobFile(test_newjob_name)
+	if err != nil {
+		t.Fatalf("test resulted in error: %v", err)
+	}
+
+	history := res.History()
+	if len(history) != 2 || history[1].Kind != HistorySaved || history[1].LastEditingIndex != 30 {
+		t.Errorf("autosaves were not folded into the save, got %#v", history)
+	}
+	for _, file := range autosaved {
+		if _, err := os.Stat(file); err == nil {
+			t.Errorf("autosaved %s should be removed", file)
+		}
+	}
+	if content, err := os.ReadFile(res.LatestEditFile()); err != nil || string(content) != "edited at last" {
+		t.Errorf("saved edit file was not written: %v", err)
+	}
+}
+
+func TestReapply(t *testing.T) {
+	store := &Store{JobDirectory: t.TempDir()}
+	dir := t.TempDir()
+	editFile := path.Join(dir, "iliad.txt")
+	sourceFile := path.Join(dir, "scan.txt")
+
+	os.WriteFile(editFile, []byte("Sing, O goddess, the angr of Achilles son of Peleus,\nthat brought countless ills upon the Achaeans.\n"), 0644)
+	os.WriteFile(sourceFile, []byte("Sing, O goddess, the anger of Achilles son of Peleus,\n"), 0644)
+
+	job, err := store.FromEditAndSourceFiles(editFile, sourceFile)
+	if err != nil {
+		t.Fatalf("couldn't create job: %v", err)
+	}
+
+	//	resolve the typo, leaving off at "Achilles"
+	err = job.SaveSession("Sing, O goddess, the anger of Achilles son of Peleus,\nthat brought countless ills upon the Achaeans.\n",
+		"Sing, O goddess, the anger of Achilles son of Peleus,\n", 6, 6, HistorySaved)
+	if err != nil {
+		t.Fatalf("couldn't save session: %v", err)
+	}
+
+	resumed, err := store.FromJobFile(job.Name())
+	if err != nil {
+		t.Fatalf("couldn't resume job: %v", err)
+	}
+	if len(resumed.ChangedOriginals()) != 0 {
+		t.Fatalf("unchanged originals reported as changed: %v", resumed.ChangedOriginals())
+	}
+
+	//	upstream re-release adds a heading and fixes a different word
+	os.WriteFile(editFile, []byte("BOOK I\n\nSing, O goddess, the angr of Achilles son of Peleus,\nthat brought countless woes upon the Achaeans.\n"), 0644)
+
+	resumed, err = store.FromJobFile(job.Name())
+	if err != nil {
+		t.Fatalf("couldn't resume job: %v", err)
+	}
+	if !slices.Equal(resumed.ChangedOriginals(), []string{editFile}) {
+		t.Fatalf("got changed originals %v, want %v", resumed.ChangedOriginals(), []string{editFile})
+	}
+
+	results, err := resumed.Reapply()
+	if err != nil {
+		t.Fatalf("reapply resulted in error: %v", err)
+	}
+	if len(results) != 1 || results[0].Original != editFile || results[0].Conflicts != 0 {
+		t.Errorf("got results %#v", results)
+	}
+
+	merged, _ := os.ReadFile(resumed.LatestEditFile())
+	want := "BOOK I\n\nSing, O goddess, the anger of Achilles son of Peleus,\nthat brought countless woes upon the Achaeans.\n"
+	if string(merged) != want {
+		t.Errorf("\ngot:  %q\nwant: %q", merged, want)
+	}
+
+	if resumed.LastEditingIndex != 8 || resumed.LastSourceIndex != 6 {
+		t.Errorf("got indexes %d %d, want 8 6", resumed.LastEditingIndex, resumed.LastSourceIndex)
+	}
+
+	resumed, err = store.FromJobFile(job.Name())
+	if err != nil {
+		t.Fatalf("couldn't resume job: %v", err)
+	}
+	if len(resumed.ChangedOriginals()) != 0 {
+		t.Errorf("re-applied original still reported as changed")
+	}
+}
+
+func TestReapplySplitPunctuation(t *testing.T) {
+	store := &Store{JobDirectory: t.TempDir()}
+	dir := t.TempDir()
+	editFile := path.Join(dir, "iliad.txt")
+	sourceFile := path.Join(dir, "scan.txt")
+
+	os.WriteFile(editFile, []byte("Sing, O goddess, the angr of Achilles son of Peleus,\n"), 0644)
+	os.WriteFile(sourceFile, []byte("Sing, O goddess, the anger of Achilles son of Peleus,\n"), 0644)
+
+	job, err := store.FromEditAndSourceFiles(editFile, sourceFile)
+	if err != nil {
+		t.Fatalf("couldn't create job: %v", err)
+	}
+	job.SetSetting("punctuation", "split")
+
+	//	leave off at "Achilles", which is word 8 with the commas as words of their own
+	err = job.SaveSession("Sing, O goddess, the anger of Achilles son of Peleus,\n",
+		"Sing, O goddess, the anger of Achilles son of Peleus,\n", 8, 8, HistorySaved)
+	if err != nil {
+		t.Fatalf("couldn't save session: %v", err)
+	}
+
+	//	upstream re-release drops the commas, which moves "Achilles" back two words but
+	//	leaves the whitespace separated words as they were
+	os.WriteFile(editFile, []byte("Sing O goddess the angr of Achilles son of Peleus,\n"), 0644)
+
+	resumed, err := store.FromJobFile(job.Name())
+	if err != nil {
+		t.Fatalf("couldn't resume job: %v", err)
+	}
+	if _, err := resumed.Reapply(); err != nil {
+		t.Fatalf("reapply resulted in error: %v", err)
+	}
+
+	merged, _ := os.ReadFile(resumed.LatestEditFile())
+	if want := "Sing O goddess the anger of Achilles son of Peleus,\n"; string(merged) != want {
+		t.Errorf("\ngot:  %q\nwant: %q", merged, want)
+	}
+	if resumed.LastEditingIndex != 6 || resumed.LastSourceIndex != 8 {
+		t.Errorf("got indexes %d %d, want 6 8", resumed.LastEditingIndex, resumed.LastSourceIndex)
+	}
+}
+
+func TestReport(t *testing.T) {
+	store := &Store{JobDirectory: t.TempDir()}
+	dir := t.TempDir()
+	editFile := path.Join(dir, "iliad.txt")
+	sourceFile := path.Join(dir, "iliad_djvu.txt")
+
+	os.WriteFile(editFile, []byte("BOOK I.\n\nSing, goddess, the wrath of Achillcs Peleus' son, the ruinous wrath that brought woes\n"), 0644)
+	os.WriteFile(sourceFile, []byte("BOOK I.\n\nSing, goddess,\f\nthe wrath of Achilles Peleus’ son,\f\nthe ruinous wrath that brought on woes\n"), 0644)
+
+	job, err := store.FromEditAndSourceFiles(editFile, sourceFile)
+	if err != nil {
+		t.Fatalf("couldn't create job: %v", err)
+	}
+
+	out := strings.Builder{}
+	if err := job.Report(&out); err != nil {
+		t.Fatalf("report resulted in error: %v", err)
+	}
+
+	want := "BOOK I.  source page 2  [i = 7] [j = 7]\n" +
+		"\tfile under edit: goddess, the wrath of *Achillcs* Peleus' son, the ruinous\n" +
+		"\tsource file:     goddess, the wrath of *Achilles* Peleus' son, the ruinous\n\n" +
+		"BOOK I.  source page 3  [i = 15] [j = 15]\n" +
+		"\tfile under edit: ruinous wrath that brought ** woes\n" +
+		"\tsource file:     ruinous wrath that brought *on* woes\n\n" +
+		"2 discrepancies left\n"
+	if out.String() != want {
+		t.Errorf("\ngot:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,167 @@
+package editingjob
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"poweredit/diff"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Edition is one saved version of a job's edit and source files
+type Edition struct {
+	Number       int
+	EditFile     string
+	SourceFile   string
+	EditingIndex int       // where the session that saved this edition left off in the edit file
+	SourceIndex  int       // where the session that saved this edition left off in the source file
+	Saved        time.Time // when the edition was saved, zero if its edit file is missing
+}
+
+// Editions lists the job's editions in the order they were first recorded in its history
+func (ej *EditingJob) Editions() ([]Edition, error) {
+	editions := []Edition{}
+	seen := map[int]bool{}
+
+	for _, entry := range ej.history {
+		//	a rollback points at an edition that was recorded earlier,
+		//	the first entry for an edition is the session that created it
+		if seen[entry.Edition] {
+			continue
+		}
+		seen[entry.Edition] = true
+
+		edition := Edition{
+			Number:       entry.Edition,
+			EditFile:     entry.EditFile,
+			SourceFile:   entry.SourceFile,
+			EditingIndex: entry.LastEditingIndex,
+			SourceIndex:  entry.LastSourceIndex,
+			Saved:        entry.Time,
+		}
+
+		if info, err := os.Stat(edition.EditFile); err != nil {
+			edition.Saved = time.Time{}
+		} else if edition.Saved.IsZero() {
+			edition.Saved = info.ModTime()
+		}
+
+		editions = append(editions, edition)
+	}
+
+	return editions, nil
+}
+
+// Edition returns edition n of the job
+func (ej *EditingJob) Edition(n int) (Edition, error) {
+	editions, err := ej.Editions()
+	if err != nil {
+		return Edition{}, err
+	}
+
+	for _, edition := range editions {
+		if edition.Number == n {
+			return edition, nil
+		}
+	}
+
+	return Edition{}, fmt.Errorf("job %s has no edition %d", ej.name, n)
+}
+
+// Rollback points the job back at edition n, recording the rollback in the job's history.
+// Later editions are kept, and the next save is numbered after the newest of them
+func (ej *EditingJob) Rollback(n int) error {
+	edition, err := ej.Edition(n)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(edition.EditFile); err != nil {
+		return fmt.Errorf("can't roll back to edition %d: %v", n, err)
+	}
+	if _, err := os.Stat(edition.SourceFile); err != nil {
+		return fmt.Errorf("can't roll back to edition %d: %v", n, err)
+	}
+
+	ej.latestEditFile = edition.EditFile
+	ej.latestSourceFile = edition.SourceFile
+	ej.LastEditingIndex = edition.EditingIndex
+	ej.LastSourceIndex = edition.SourceIndex
+
+	return ej.record(HistoryRollback)
+}
+
+// DisplayEditions prints the job's editions, marking the one the job currently points at
+func (ej *EditingJob) DisplayEditions() error {
+	editions, err := ej.Editions()
+	if err != nil {
+		return fmt.Errorf("could not display editions: %v", err)
+	}
+
+	for _, edition := range editions {
+		current := " "
+		if edition.EditFile == ej.latestEditFile {
+			current = "*"
+		}
+
+		saved := "missing"
+		if !edition.Saved.IsZero() {
+			saved = edition.Saved.Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Printf("%s %3d  %s  [i = %d] [j = %d]\n", current, edition.Number, saved, edition.EditingIndex, edition.SourceIndex)
+	}
+
+	return nil
+}
+
+// DiffEditions writes a unified diff of the edit files, then the source files, of editions a and b
+func (ej *EditingJob) DiffEditions(w io.Writer, a, b int) error {
+	from, err := ej.Edition(a)
+	if err != nil {
+		return err
+	}
+
+	to, err := ej.Edition(b)
+	if err != nil {
+		return err
+	}
+
+	if err := diffFiles(w, from.EditFile, to.EditFile); err != nil {
+		return err
+	}
+
+	return diffFiles(w, from.SourceFile, to.SourceFile)
+}
+
+func diffFiles(w io.Writer, fileA, fileB string) error {
+	a, err := os.ReadFile(fileA)
+	if err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(fileB)
+	if err != nil {
+		return err
+	}
+
+	return diff.WriteUnified(w, fileA, fileB, strings.Split(string(a), "\n"), strings.Split(string(b), "\n"), 3)
+}
+
+// editionNumber reads the edition from an edition file name such as 3_iliad.txt
+func editionNumber(filename string) (int, error) {
+	prefix, _, found := strings.Cut(filepath.Base(filename), "_")
+	if !found {
+		return 0, fmt.Errorf("%s is not an edition file", filename)
+	}
+
+	n, err := strconv.Atoi(prefix)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not an edition file: %v", filename, err)
+	}
+
+	return n, nil
+}
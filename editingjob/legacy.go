@@ -0,0 +1,117 @@
+package editingjob
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// readLegacyJobFile reads a CSV job file, as written before job manifests, into a job
+// whose history holds one entry per row
+func (s *Store) readLegacyJobFile(filename string) (*EditingJob, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) < 2 {
+		return nil, fmt.Errorf("CSV file must have at least one record")
+	}
+
+	//	find each column by its header, rather than by position
+	column := map[string]int{}
+	for i, header := range records[0] {
+		column[header] = i
+	}
+	for _, field := range (&EditingJob{}).FieldNameSlice() {
+		if _, ok := column[field]; !ok {
+			return nil, fmt.Errorf("CSV job file %s has no %s column\nconsider reviewing file", filename, field)
+		}
+	}
+
+	job := &EditingJob{store: s}
+
+	for n, row := range records[1:] {
+		if len(row) < len(records[0]) {
+			return nil, fmt.Errorf("row %d of CSV job file %s is missing fields", n+2, filename)
+		}
+
+		latestEdition, err := strconv.Atoi(row[column["latest_edition"]])
+		if err != nil {
+			return nil, err
+		}
+
+		lastEditingIndex, err := strconv.Atoi(row[column["last_editing_index"]])
+		if err != nil {
+			return nil, err
+		}
+
+		lastSourceIndex, err := strconv.Atoi(row[column["last_source_index"]])
+		if err != nil {
+			return nil, err
+		}
+
+		job.name = row[column["name"]]
+		job.editingFile = row[column["editing_file"]]
+		job.sourceFile = row[column["source_file"]]
+		job.latestEditFile = row[column["latest_edit_file"]]
+		job.latestSourceFile = row[column["latest_source_file"]]
+		job.latestEdition = latestEdition
+		job.LastEditingIndex = lastEditingIndex
+		job.LastSourceIndex = lastSourceIndex
+
+		//	rows written by a rollback keep the newest edition in latest_edition,
+		//	so take the edition a row points at from its file name
+		edition, err := editionNumber(job.latestEditFile)
+		if err != nil {
+			edition = latestEdition
+		}
+
+		job.history = append(job.history, HistoryEntry{
+			Kind:             HistoryImported,
+			Edition:          edition,
+			EditFile:         job.latestEditFile,
+			SourceFile:       job.latestSourceFile,
+			LastEditingIndex: lastEditingIndex,
+			LastSourceIndex:  lastSourceIndex,
+		})
+	}
+
+	return job, nil
+}
+
+// migrate writes a manifest for a job read from a CSV job file, then moves the CSV job
+// file aside to <name>.csv.bak. It is done holding the job's lock, and if another session
+// has migrated the job since it was read, the job has to be read again from its manifest
+func (ej *EditingJob) migrate() error {
+	if ej.legacy == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(ej.manifestPath()); err == nil {
+		return fmt.Errorf("job %s was migrated to a manifest by another session, open it again", ej.name)
+	}
+
+	return ej.writeManifest()
+}
+
+// finishMigration moves the CSV job file aside once the manifest replacing it is written
+func (ej *EditingJob) finishMigration() error {
+	if ej.legacy == "" {
+		return nil
+	}
+
+	if err := os.Rename(ej.legacy, ej.legacy+".bak"); err != nil {
+		return fmt.Errorf("migrated %s to a job manifest but couldn't rename it: %v", ej.legacy, err)
+	}
+	ej.legacy = ""
+	return nil
+}
@@ -0,0 +1,182 @@
+package editingjob
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Lock is an advisory lock on a job, kept as <job directory>/<name>.lock while a
+// session has the job open, so a second session can't overwrite the first one's edition
+type Lock struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Started time.Time `json:"started"`
+
+	path string
+}
+
+// LockedError is returned when a job is already locked by a live session
+type LockedError struct {
+	Job    string
+	Holder Lock
+}
+
+// lockGrace is how long a lock that can't be read is taken to be still being written
+const lockGrace = 10 * time.Second
+
+func (e *LockedError) Error() string {
+	if e.Holder.PID == 0 {
+		return fmt.Sprintf("job %s is being opened by another session", e.Job)
+	}
+	return fmt.Sprintf("job %s is open in another session (pid %d on %s since %s)",
+		e.Job, e.Holder.PID, e.Holder.Host, e.Holder.Started.Format("2006-01-02 15:04:05"))
+}
+
+func (ej *EditingJob) lockPath() string {
+	return filepath.Join(ej.store.JobDirectory, ej.name, ej.name+".lock")
+}
+
+// Lock takes the job's lock for this process. A lock left behind by a session that
+// is no longer running on this host is taken over; a lock held by a live session,
+// or by one on another host, results in a *LockedError. A job read from a CSV job
+// file is migrated to a manifest once it is locked
+func (ej *EditingJob) Lock() (*Lock, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't lock job %s: %v", ej.name, err)
+	}
+
+	lock := &Lock{
+		PID:     os.Getpid(),
+		Host:    host,
+		Started: time.Now(),
+		path:    ej.lockPath(),
+	}
+
+	content, err := json.Marshal(lock)
+	if err != nil {
+		return nil, err
+	}
+
+	//	the lock is written aside and linked into place whole, as linking fails when the
+	//	lock exists, so another session never reads a lock that is only partly written
+	file, err := os.CreateTemp(filepath.Dir(lock.path), ej.name+".lock.*")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't lock job %s: %v", ej.name, err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't lock job %s: %v", ej.name, err)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		err := os.Link(file.Name(), lock.path)
+		if err == nil {
+			if err := ej.migrate(); err != nil {
+				lock.Release()
+				return nil, err
+			}
+			return lock, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("couldn't lock job %s: %v", ej.name, err)
+		}
+
+		holder, err := ej.LockHolder()
+		if err != nil {
+			return nil, err
+		}
+		if holder == nil {
+			continue //	released between our attempt and reading it
+		}
+		if !holder.Stale() {
+			return nil, &LockedError{Job: ej.name, Holder: *holder}
+		}
+
+		if err := os.Remove(lock.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("couldn't remove stale lock on job %s: %v", ej.name, err)
+		}
+	}
+
+	return nil, fmt.Errorf("couldn't lock job %s, another session is starting", ej.name)
+}
+
+// LockHolder returns the lock currently held on the job, or nil if it isn't locked
+func (ej *EditingJob) LockHolder() (*Lock, error) {
+	content, err := os.ReadFile(ej.lockPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	holder := &Lock{path: ej.lockPath()}
+	if err := json.Unmarshal(content, holder); err != nil {
+		//	locks are linked into place whole, but an older version may still be writing
+		//	one, so a lock that can't be read is only given up once it is old
+		info, err := os.Stat(ej.lockPath())
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &Lock{Started: info.ModTime(), path: ej.lockPath()}, nil
+	}
+
+	return holder, nil
+}
+
+// Unlock forcibly removes the job's lock, for locks left by a session on another
+// host that can't be checked for staleness
+func (ej *EditingJob) Unlock() error {
+	err := os.Remove(ej.lockPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Stale reports whether the session holding the lock has gone away. Only a lock
+// taken on this host can be checked, locks from other hosts are never stale. A lock
+// that can't be read is stale once it is older than lockGrace
+func (l *Lock) Stale() bool {
+	if l.PID == 0 {
+		return time.Since(l.Started) > lockGrace
+	}
+
+	host, err := os.Hostname()
+	if err != nil || host != l.Host {
+		return false
+	}
+
+	return !processRunning(l.PID)
+}
+
+// Release gives up the lock, if it is still ours
+func (l *Lock) Release() error {
+	content, err := os.ReadFile(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var holder Lock
+	if err := json.Unmarshal(content, &holder); err != nil || holder.PID != l.PID || holder.Host != l.Host {
+		return nil
+	}
+
+	return os.Remove(l.path)
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package editingjob
+
+import (
+	"errors"
+	"syscall"
+)
+
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package editingjob
+
+import "os"
+
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
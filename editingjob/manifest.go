@@ -0,0 +1,144 @@
+package editingjob
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"poweredit/utils"
+	"time"
+)
+
+// SchemaVersion is the version of the job manifest format written by this version of PowerEdit.
+// Bump it whenever a change to the manifest can't be read correctly by older versions
+const SchemaVersion = 1
+
+// kinds of HistoryEntry
+const (
+	HistoryCreated   = "created"   // the job was created, pointing at edition 0
+	HistorySaved     = "saved"     // a session saved a new edition
+	HistoryAutosave  = "autosave"  // a session saved part way through, replaced by its next save
+	HistoryRollback  = "rollback"  // the job was pointed back at an earlier edition
+	HistoryImported  = "imported"  // a row of a CSV job file the manifest was migrated from
+	HistoryReapplied = "reapplied" // the job's decisions were re-applied to a changed original
+)
+
+// HistoryEntry records a point the job was left at, either by a session or by a command such as rollback
+type HistoryEntry struct {
+	Kind             string    `json:"kind"`
+	Edition          int       `json:"edition"`
+	EditFile         string    `json:"edit_file"`
+	SourceFile       string    `json:"source_file"`
+	LastEditingIndex int       `json:"last_editing_index"`
+	LastSourceIndex  int       `json:"last_source_index"`
+	Time             time.Time `json:"time,omitempty"` // zero for entries imported from a CSV job file
+}
+
+// manifest is the on disk form of an EditingJob, kept as <job directory>/<name>.json
+type manifest struct {
+	SchemaVersion    int               `json:"schema_version"`
+	Name             string            `json:"name"`
+	EditingFile      string            `json:"editing_file"`
+	SourceFile       string            `json:"source_file"`
+	LatestEditFile   string            `json:"latest_edit_file"`
+	LatestSourceFile string            `json:"latest_source_file"`
+	LatestEdition    int               `json:"latest_edition"`
+	LastEditingIndex int               `json:"last_editing_index"`
+	LastSourceIndex  int               `json:"last_source_index"`
+	EditingSum       string            `json:"editing_file_sha256,omitempty"`
+	SourceSum        string            `json:"source_file_sha256,omitempty"`
+	EditingBase      string            `json:"editing_base,omitempty"`
+	SourceBase       string            `json:"source_base,omitempty"`
+	Notes            string            `json:"notes,omitempty"`
+	Settings         map[string]string `json:"settings,omitempty"`
+	History          []HistoryEntry    `json:"history"`
+}
+
+func (ej *EditingJob) manifestPath() string {
+	return filepath.Join(ej.store.JobDirectory, ej.name, ej.name+".json")
+}
+
+// ReadEditingJob reads a job from its manifest
+func (s *Store) ReadEditingJob(filename string) (*EditingJob, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	//	read the version on its own first, so a manifest from a newer PowerEdit is refused
+	//	rather than half understood
+	var version struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(content, &version); err != nil {
+		return nil, fmt.Errorf("couldn't read job manifest %s: %v", filename, err)
+	}
+
+	if version.SchemaVersion < 1 {
+		return nil, fmt.Errorf("job manifest %s has no schema version\nconsider reviewing file", filename)
+	}
+	if version.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("job manifest %s has schema version %d, this version of PowerEdit reads up to %d",
+			filename, version.SchemaVersion, SchemaVersion)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, fmt.Errorf("couldn't read job manifest %s: %v", filename, err)
+	}
+
+	return &EditingJob{
+		store:            s,
+		name:             m.Name,
+		editingFile:      m.EditingFile,
+		sourceFile:       m.SourceFile,
+		latestEditFile:   m.LatestEditFile,
+		latestSourceFile: m.LatestSourceFile,
+		latestEdition:    m.LatestEdition,
+		LastEditingIndex: m.LastEditingIndex,
+		LastSourceIndex:  m.LastSourceIndex,
+		editingSum:       m.EditingSum,
+		sourceSum:        m.SourceSum,
+		editingBase:      m.EditingBase,
+		sourceBase:       m.SourceBase,
+		notes:            m.Notes,
+		settings:         m.Settings,
+		history:          m.History,
+	}, nil
+}
+
+// writeManifest atomically replaces the job's manifest, so an interrupted write can't lose the job
+func (ej *EditingJob) writeManifest() error {
+	content, err := json.MarshalIndent(manifest{
+		SchemaVersion:    SchemaVersion,
+		Name:             ej.name,
+		EditingFile:      ej.editingFile,
+		SourceFile:       ej.sourceFile,
+		LatestEditFile:   ej.latestEditFile,
+		LatestSourceFile: ej.latestSourceFile,
+		LatestEdition:    ej.latestEdition,
+		LastEditingIndex: ej.LastEditingIndex,
+		LastSourceIndex:  ej.LastSourceIndex,
+		EditingSum:       ej.editingSum,
+		SourceSum:        ej.sourceSum,
+		EditingBase:      ej.editingBase,
+		SourceBase:       ej.sourceBase,
+		Notes:            ej.notes,
+		Settings:         ej.settings,
+		History:          ej.history,
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err := utils.UpdateFile(ej.manifestPath(), string(content)+"\n"); err != nil {
+		return fmt.Errorf("couldn't write job manifest %s: %v", ej.manifestPath(), err)
+	}
+
+	return ej.finishMigration()
+}
+
+// SaveManifest writes the job's notes and settings without adding to its history
+func (ej *EditingJob) SaveManifest() error {
+	return ej.writeManifest()
+}
@@ -0,0 +1,153 @@
+package editingjob
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"poweredit/utils"
+)
+
+// UsesSharedTexts reports whether any of the job's editions are still kept in the
+// store's shared TextDirectory, where another job with same named files could overwrite them
+func (ej *EditingJob) UsesSharedTexts() (bool, error) {
+	for _, entry := range ej.history {
+		if filepath.Dir(entry.EditFile) != ej.textDirectory() || filepath.Dir(entry.SourceFile) != ej.textDirectory() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// MigrateTexts copies every edition in the job's history into the job's own text
+// directory and rewrites the manifest to point at the copies. The originals are left
+// in place since a colliding job may still be using them. Editions which can no longer
+// be found are left pointing at their old location. Returns the number of files copied
+func (ej *EditingJob) MigrateTexts() (int, error) {
+	if err := createFileIfNotExist(ej.textDirectory()); err != nil {
+		return 0, err
+	}
+
+	copied := 0
+	moved := map[string]string{}
+
+	migrate := func(old string) (string, error) {
+		if filepath.Dir(old) == ej.textDirectory() {
+			return old, nil
+		}
+
+		if to, done := moved[old]; done {
+			return to, nil
+		}
+
+		if _, err := os.Stat(old); errors.Is(err, fs.ErrNotExist) {
+			return old, nil
+		}
+
+		to := filepath.Join(ej.textDirectory(), filepath.Base(old))
+		if _, err := os.Stat(to); err == nil {
+			//	left behind by an earlier migration that didn't finish
+			if same, err := sameContent(old, to); err != nil || !same {
+				return old, fmt.Errorf("can't migrate %s, a different %s already exists", old, to)
+			}
+		} else {
+			if err := utils.CopyFile(old, to); err != nil {
+				return old, fmt.Errorf("couldn't copy %s to %s: %v", old, to, err)
+			}
+			copied++
+		}
+
+		moved[old] = to
+		return to, nil
+	}
+
+	history := make([]HistoryEntry, len(ej.history))
+	copy(history, ej.history)
+
+	for n := range history {
+		var err error
+		if history[n].EditFile, err = migrate(history[n].EditFile); err != nil {
+			return copied, err
+		}
+		if history[n].SourceFile, err = migrate(history[n].SourceFile); err != nil {
+			return copied, err
+		}
+	}
+
+	latestEditFile, err := migrate(ej.latestEditFile)
+	if err != nil {
+		return copied, err
+	}
+	latestSourceFile, err := migrate(ej.latestSourceFile)
+	if err != nil {
+		return copied, err
+	}
+
+	previous := *ej
+	ej.history = history
+	ej.latestEditFile = latestEditFile
+	ej.latestSourceFile = latestSourceFile
+
+	if err := ej.writeManifest(); err != nil {
+		*ej = previous
+		return copied, err
+	}
+
+	return copied, nil
+}
+
+// MigrateAllTexts runs MigrateTexts for every job. A job that can't be migrated, eg. because
+// it is open in a session, doesn't stop the rest; the errors of all such jobs are returned together
+func (s *Store) MigrateAllTexts() error {
+	jobs, err := s.getAllJobs()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, entry := range jobs {
+		if !entry.IsDir() {
+			continue
+		}
+
+		job, err := s.FromJobFile(entry.Name() + ".csv")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("couldn't read job %s: %v", entry.Name(), err))
+			continue
+		}
+
+		lock, err := job.Lock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("couldn't migrate job %s: %v", entry.Name(), err))
+			continue
+		}
+
+		copied, err := job.MigrateTexts()
+		lock.Release()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("couldn't migrate job %s: %v", entry.Name(), err))
+			continue
+		}
+
+		fmt.Printf("%s: %d files copied into %s\n", entry.Name(), copied, job.textDirectory())
+	}
+
+	return errors.Join(errs...)
+}
+
+func sameContent(a, b string) (bool, error) {
+	ac, err := os.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+
+	bc, err := os.ReadFile(b)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(ac, bc), nil
+}
@@ -0,0 +1,232 @@
+package editingjob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"poweredit/diff"
+	"poweredit/textwords"
+	"poweredit/utils"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Reapplied is the result of re-applying a job's decisions to a changed original
+type Reapplied struct {
+	Original  string
+	Conflicts int // places the job and the new original changed the same words differently; the job's version was kept
+}
+
+// ChangedOriginals lists the original files that no longer match the fingerprint taken when
+// the job was created, as found when the job was read by FromJobFile
+func (ej *EditingJob) ChangedOriginals() []string {
+	return ej.changed
+}
+
+// checkOriginals fingerprints the job's original files and compares them against the fingerprints
+// taken when the job was created. Jobs from before fingerprints were kept are fingerprinted from
+// the copies of the originals the job was created with
+func (ej *EditingJob) checkOriginals() {
+	ej.changed = nil
+
+	for _, original := range ej.originals() {
+		if *original.sum == "" {
+			base, err := fileSum(original.base)
+			if err != nil {
+				continue
+			}
+			*original.sum = base
+		}
+
+		//	a missing original can't be compared; the job carries on from its own copies
+		got, err := fileSum(original.path)
+		if err != nil {
+			continue
+		}
+
+		if got != *original.sum {
+			ej.changed = append(ej.changed, original.path)
+		}
+	}
+}
+
+type originalFile struct {
+	path   string  // where the original is
+	base   string  // the copy of the original the job's editions derive from
+	latest string  // the job's latest edition of the original
+	sum    *string // the fingerprint of base
+	copy   *string // the job's record of base, "" while it is edition 0
+}
+
+func (ej *EditingJob) originals() []originalFile {
+	return []originalFile{
+		{ej.editingFile, ej.baseFile(ej.editingBase, true), ej.latestEditFile, &ej.editingSum, &ej.editingBase},
+		{ej.sourceFile, ej.baseFile(ej.sourceBase, false), ej.latestSourceFile, &ej.sourceSum, &ej.sourceBase},
+	}
+}
+
+// baseFile is the copy of an original a job's editions derive from; edition 0 until
+// the job's decisions are re-applied to a changed original
+func (ej *EditingJob) baseFile(base string, edit bool) string {
+	if base != "" {
+		return base
+	}
+
+	for _, entry := range ej.history {
+		if entry.Edition != 0 {
+			continue
+		}
+		if edit {
+			return entry.EditFile
+		}
+		return entry.SourceFile
+	}
+
+	if edit {
+		return filepath.Join(ej.textDirectory(), "0_"+filepath.Base(ej.editingFile))
+	}
+	return filepath.Join(ej.textDirectory(), "0_"+filepath.Base(ej.sourceFile))
+}
+
+// Reapply re-applies the job's decisions to each changed original, saving the result as a new
+// edition. Each file is merged word by word: the changes made between the job's copy of the
+// original and its latest edition are applied to the new original. The indexes the job left
+// off at are moved to the same words in the new edition
+func (ej *EditingJob) Reapply() ([]Reapplied, error) {
+	if len(ej.changed) == 0 {
+		return nil, nil
+	}
+
+	previous := *ej
+	results := []Reapplied{}
+	texts := []string{}
+	indexes := []int{ej.LastEditingIndex, ej.LastSourceIndex}
+	copies := []string{}
+
+	fail := func(err error) ([]Reapplied, error) {
+		for _, c := range copies {
+			os.Remove(c)
+		}
+		*ej = previous
+		return nil, err
+	}
+
+	for n, original := range ej.originals() {
+		latest, err := os.ReadFile(original.latest)
+		if err != nil {
+			return fail(err)
+		}
+
+		if !slices.Contains(ej.changed, original.path) {
+			texts = append(texts, string(latest))
+			continue
+		}
+
+		if strings.EqualFold(filepath.Ext(original.path), ".epub") {
+			return fail(fmt.Errorf("can't re-apply the job's decisions to %s, EPUBs can't be merged", original.path))
+		}
+
+		base, err := os.ReadFile(original.base)
+		if err != nil {
+			return fail(fmt.Errorf("couldn't read the job's copy of %s: %v", original.path, err))
+		}
+
+		theirs, err := os.ReadFile(original.path)
+		if err != nil {
+			return fail(err)
+		}
+
+		//	merge as UTF-8 text, then write in the format of the new original,
+		//	in case a re-release also changed the encoding or line endings
+		ours := decode(latest)
+		format := textwords.DetectFormat(theirs)
+		merged, conflicts := diff.Merge3(tokens(decode(base)), tokens(ours), tokens(textwords.Decode(theirs, format)))
+
+		content, err := textwords.Encode(strings.Join(merged, ""), format)
+		if err != nil {
+			format.Encoding = textwords.UTF8
+			content, _ = textwords.Encode(strings.Join(merged, ""), format)
+		}
+
+		//	the indexes are into the words as a session compares them, which aren't the merge's
+		//	tokens when punctuation is split off or the file has markup
+		before, err := ej.wordsOf(latest, original.latest)
+		if err != nil {
+			return fail(err)
+		}
+		after, err := ej.wordsOf(content, original.path)
+		if err != nil {
+			return fail(err)
+		}
+		indexes[n] = diff.Map(diff.Compute(compared(before, 0, before.Len()), compared(after, 0, after.Len())), indexes[n])
+
+		//	keep a copy of the new original, for the next time it changes
+		kept := filepath.Join(ej.textDirectory(), fmt.Sprintf("original_%d_%s", ej.latestEdition+1, filepath.Base(original.path)))
+		if err := utils.UpdateFile(kept, string(theirs)); err != nil {
+			return fail(fmt.Errorf("couldn't keep a copy of %s: %v", original.path, err))
+		}
+		copies = append(copies, kept)
+
+		*original.copy = kept
+		*original.sum = sum(theirs)
+
+		texts = append(texts, string(content))
+		results = append(results, Reapplied{Original: original.path, Conflicts: len(conflicts)})
+	}
+
+	if err := ej.SaveSession(texts[0], texts[1], indexes[0], indexes[1], HistoryReapplied); err != nil {
+		return fail(err)
+	}
+	ej.changed = nil
+
+	return results, nil
+}
+
+func decode(b []byte) string {
+	return textwords.Decode(b, textwords.DetectFormat(b))
+}
+
+// tokens splits text into words and the whitespace between them, so that joining the tokens
+// gives back the text
+func tokens(text string) []string {
+	ts := []string{}
+	start := 0
+	space := false
+
+	for i, char := range text {
+		if i > start && unicode.IsSpace(char) != space {
+			ts = append(ts, text[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(char)
+	}
+	if start < len(text) {
+		ts = append(ts, text[start:])
+	}
+
+	return ts
+}
+
+func fileSum(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sum(content []byte) string {
+	s := sha256.Sum256(content)
+	return hex.EncodeToString(s[:])
+}
@@ -0,0 +1,96 @@
+package editingjob
+
+import (
+	"fmt"
+	"io"
+	"poweredit/textwords"
+	"poweredit/utils"
+	"strings"
+)
+
+// words either side of a discrepancy shown in a report
+const reportContext = 4
+
+// Report writes the discrepancies left between the job's latest edit and source files, citing
+// the chapter each is in, and the page of the scanned source where the source's pages are known,
+// so the facsimile page can be opened directly
+func (ej *EditingJob) Report(w io.Writer) error {
+	edit, err := ej.Words(ej.latestEditFile)
+	if err != nil {
+		return fmt.Errorf("couldn't read %s: %v", ej.latestEditFile, err)
+	}
+
+	source, err := ej.Words(ej.latestSourceFile)
+	if err != nil {
+		return fmt.Errorf("couldn't read %s: %v", ej.latestSourceFile, err)
+	}
+
+	editStart, editEnd := ej.Body(edit)
+	sourceStart, sourceEnd := ej.Body(source)
+
+	anchors := textwords.PairChapters(edit, source)
+
+	a := compared(edit, 0, edit.Len())
+	b := compared(source, 0, source.Len())
+	n := 0
+
+	for h := range ej.Discrepancies(edit, source) {
+		i := h.A
+		j := h.B
+		n++
+
+		chapter := ""
+		for _, anchor := range anchors {
+			if anchor.Source > j {
+				break
+			}
+			chapter = anchor.Title
+		}
+
+		//	a word missing from the source is cited at the page of the word it would follow
+		page := 0
+		if at := min(j, sourceEnd-1); at >= sourceStart {
+			page = source.GetWord(at).Page()
+		}
+
+		if chapter != "" {
+			fmt.Fprintf(w, "%s  ", chapter)
+		}
+		if page > 0 {
+			fmt.Fprintf(w, "source page %d  ", page)
+		}
+		fmt.Fprintf(w, "[i = %d] [j = %d]\n", i, j)
+		fmt.Fprintf(w, "\tfile under edit: %s\n", excerpt(a[editStart:editEnd], h.A-editStart, h.ALen))
+		fmt.Fprintf(w, "\tsource file:     %s\n\n", excerpt(b[sourceStart:sourceEnd], h.B-sourceStart, h.BLen))
+	}
+
+	if n == 0 {
+		fmt.Fprintln(w, "no discrepancies left")
+		return nil
+	}
+	fmt.Fprintf(w, "%d discrepancies left\n", n)
+	return nil
+}
+
+// compared lists the words from start to end the way a session compares them
+func compared(tw *textwords.TextWords, start, end int) []string {
+	words := make([]string, 0, end-start)
+	for at := start; at < end; at++ {
+		words = append(words, utils.ReplaceQuotes(tw.GetWord(at).W))
+	}
+	return words
+}
+
+// excerpt shows the n words from at marked with *, with a few words either side
+func excerpt(words []string, at, n int) string {
+	before := words[max(0, at-reportContext):at]
+	changed := words[at : at+n]
+	after := words[at+n : min(len(words), at+n+reportContext)]
+
+	marked := "**"
+	if n > 0 {
+		marked = "*" + strings.Join(changed, " ") + "*"
+	}
+
+	return strings.TrimSpace(strings.Join(before, " ") + " " + marked + " " + strings.Join(after, " "))
+}
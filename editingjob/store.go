@@ -0,0 +1,125 @@
+package editingjob
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Store is the directory PowerEdit keeps its jobs in. Jobs read or created
+// through a Store keep their files beneath it
+type Store struct {
+	JobDirectory  string
+	TextDirectory string // shared edition directory used before editions were kept per job, see MigrateTexts
+}
+
+// NewStore returns a Store rooted at root, creating its job directory if needed
+func NewStore(root string) (*Store, error) {
+	s := &Store{
+		JobDirectory:  filepath.Join(root, "jobs"),
+		TextDirectory: filepath.Join(root, "texts"),
+	}
+
+	if err := createFileIfNotExist(s.JobDirectory); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ResolveRoot decides where PowerEdit's data lives. In order of preference:
+// the given root (eg. from a --home flag), $POWEREDIT_HOME, $XDG_DATA_HOME/poweredit,
+// then ~/.local/share/poweredit. The ~/.powerEdit directory used by older versions
+// is kept when it exists and the XDG directory doesn't, so existing jobs aren't lost
+func ResolveRoot(root string) (string, error) {
+	if root != "" {
+		return filepath.Abs(root)
+	}
+
+	if env := os.Getenv("POWEREDIT_HOME"); env != "" {
+		return filepath.Abs(env)
+	}
+
+	homedir, homeErr := os.UserHomeDir()
+
+	xdg := os.Getenv("XDG_DATA_HOME")
+	if xdg == "" && homeErr == nil {
+		xdg = filepath.Join(homedir, ".local", "share")
+	}
+	if xdg == "" {
+		return "", fmt.Errorf("could not locate a home directory, set POWEREDIT_HOME or use --home: %v", homeErr)
+	}
+	xdgRoot := filepath.Join(xdg, "poweredit")
+
+	if homeErr == nil && !exists(xdgRoot) {
+		legacy := filepath.Join(homedir, ".powerEdit")
+		if exists(legacy) {
+			return legacy, nil
+		}
+	}
+
+	return xdgRoot, nil
+}
+
+func exists(filename string) bool {
+	_, err := os.Stat(filename)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+func (s *Store) getAllJobs() ([]fs.DirEntry, error) {
+	files, err := os.ReadDir(s.JobDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("could not get jobs: %v", err)
+	}
+
+	return files, nil
+}
+
+func (s *Store) JobExists(jobname string) (bool, error) {
+	jobs, err := s.getAllJobs()
+	if err != nil {
+		return false, fmt.Errorf("could not display jobs: %v", err)
+	}
+
+	for _, job := range jobs {
+		if job.IsDir() {
+			if jobname == job.Name() {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// Jobs lists the names of the jobs in the store
+func (s *Store) Jobs() ([]string, error) {
+	files, err := s.getAllJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, file := range files {
+		if file.IsDir() {
+			names = append(names, file.Name())
+		}
+	}
+
+	return names, nil
+}
+
+func (s *Store) DisplayJobs() error {
+	names, err := s.Jobs()
+	if err != nil {
+		return fmt.Errorf("could not display jobs: %v", err)
+	}
+
+	for _, name := range names {
+		fmt.Println(name)
+	}
+
+	return nil
+}
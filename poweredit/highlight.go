@@ -0,0 +1,117 @@
+package poweredit
+
+import (
+	"fmt"
+	"os"
+	"poweredit/diff"
+	"strings"
+)
+
+const (
+	red   = "\x1b[31m"
+	green = "\x1b[32m"
+	reset = "\x1b[0m"
+)
+
+// useColor tells whether to highlight in color: always, never, or by default only when writing
+// to a terminal, NO_COLOR isn't set and commands aren't read from a script
+func useColor() bool {
+	switch colorFlag {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || in.script {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// wordDiff shows where the words of a discrepancy differ, character by character, and how far
+// apart they are, so a slip of a letter stands out from a different word
+func wordDiff(editWord, sourceWord string) string {
+	if editWord == sourceWord {
+		return ""
+	}
+
+	c := compareWords(editWord, sourceWord)
+
+	out := strings.Builder{}
+	if useColor() {
+		fmt.Fprintf(&out, "\tfile under edit: %s\n", colored(c.edit, c.inEdit, red))
+		fmt.Fprintf(&out, "\tsource file:     %s\n", colored(c.source, c.inSource, green))
+	} else {
+		fmt.Fprintf(&out, "\tfile under edit: %s\n\t                 %s\n", string(c.edit), carets(c.inEdit))
+		fmt.Fprintf(&out, "\tsource file:     %s\n\t                 %s\n", string(c.source), carets(c.inSource))
+	}
+	fmt.Fprintf(&out, "\tedit distance %d, %s\n\n", c.distance, c.verdict)
+
+	return out.String()
+}
+
+// comparison is where two words differ: the characters only in one word are marked, and where
+// characters are missing from a word rather than replaced, the character after the gap, which
+// is one past the end for a gap at the end
+type comparison struct {
+	edit, source     []rune
+	inEdit, inSource []bool
+	distance         int
+	verdict          string
+}
+
+func compareWords(editWord, sourceWord string) comparison {
+	a, b := []rune(editWord), []rune(sourceWord)
+	inA, inB := make([]bool, len(a)+1), make([]bool, len(b)+1)
+
+	edits := diff.Compute(a, b)
+	for k, e := range edits {
+		replaced := (k > 0 && edits[k-1].Kind != diff.Equal) || (k+1 < len(edits) && edits[k+1].Kind != diff.Equal)
+		switch e.Kind {
+		case diff.Delete:
+			for at := e.A; at < e.A+e.N; at++ {
+				inA[at] = true
+			}
+			inB[e.B] = inB[e.B] || !replaced
+		case diff.Insert:
+			for at := e.B; at < e.B+e.N; at++ {
+				inB[at] = true
+			}
+			inA[e.A] = inA[e.A] || !replaced
+		}
+	}
+
+	distance := diff.Levenshtein(a, b)
+	verdict := "a slip of a letter or two"
+	if distance > max(1, min(len(a), len(b))/3) {
+		verdict = "likely a different word"
+	}
+
+	return comparison{edit: a, source: b, inEdit: inA, inSource: inB, distance: distance, verdict: verdict}
+}
+
+func colored(chars []rune, marked []bool, color string) string {
+	out := strings.Builder{}
+	for k, char := range chars {
+		if marked[k] {
+			out.WriteString(color + string(char) + reset)
+		} else {
+			out.WriteRune(char)
+		}
+	}
+	return out.String()
+}
+
+// carets marks characters with a caret under each, a gap at the end of a word being marked
+// just past it
+func carets(marked []bool) string {
+	out := strings.Builder{}
+	for _, m := range marked {
+		if m {
+			out.WriteByte('^')
+		} else {
+			out.WriteByte(' ')
+		}
+	}
+	return strings.TrimRight(out.String(), " ")
+}
@@ -0,0 +1,97 @@
+package poweredit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareWords(t *testing.T) {
+	var tests = []struct {
+		name                string
+		edit, source        string
+		editMarks, srcMarks string // the caret lines under each word
+		distance            int
+		slip                bool
+	}{
+		//	a replaced letter is marked in both words
+		{"replaced letter", "Achillcs", "Achilles", "      ^", "      ^", 1, true},
+		//	a letter missing from the edit is marked in the source, and the letter after the gap in the edit
+		{"dropped letter", "Achiles", "Achilles", "     ^", "     ^", 1, true},
+		//	a letter missing from the end of the source is marked one past its end
+		{"extra trailing letter", "Achilless", "Achilles", "        ^", "        ^", 1, true},
+		{"unrelated words", "wrath", "goddess", "^^^^^", "^^^^^^^", 7, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := compareWords(tt.edit, tt.source)
+
+			if len(c.inEdit) != len(c.edit)+1 || len(c.inSource) != len(c.source)+1 {
+				t.Fatalf("marks should run one past the end of each word, got %d and %d", len(c.inEdit), len(c.inSource))
+			}
+			if got := carets(c.inEdit); got != tt.editMarks {
+				t.Errorf("file under edit: got %q, want %q", got, tt.editMarks)
+			}
+			if got := carets(c.inSource); got != tt.srcMarks {
+				t.Errorf("source file: got %q, want %q", got, tt.srcMarks)
+			}
+			if c.distance != tt.distance {
+				t.Errorf("got edit distance %d, want %d", c.distance, tt.distance)
+			}
+			if slip := c.verdict == "a slip of a letter or two"; slip != tt.slip {
+				t.Errorf("got verdict %q", c.verdict)
+			}
+		})
+	}
+
+	//	only the marker past the end tells a letter missing from the end from an identical word
+	c := compareWords("Achilless", "Achilles")
+	if !c.inSource[len(c.source)] || c.inSource[len(c.source)-1] {
+		t.Errorf("missing last letter should be marked past the end of the source, got %v", c.inSource)
+	}
+
+	//	a replacement marks the letters themselves, with no gap after them
+	c = compareWords("Achillcs", "Achilles")
+	if c.inEdit[7] || c.inSource[7] {
+		t.Errorf("replaced letter should not mark a gap, got %v and %v", c.inEdit, c.inSource)
+	}
+}
+
+func TestCarets(t *testing.T) {
+	var tests = []struct {
+		marked []bool
+		want   string
+	}{
+		{[]bool{false, false, false}, ""},
+		{[]bool{true, false, false}, "^"},
+		{[]bool{false, true, false, true}, " ^ ^"},
+	}
+
+	for _, tt := range tests {
+		if got := carets(tt.marked); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.marked, got, tt.want)
+		}
+	}
+}
+
+func TestWordDiff(t *testing.T) {
+	defer func(flag string) { colorFlag = flag }(colorFlag)
+	colorFlag = "never"
+
+	got := wordDiff("Achillcs", "Achilles")
+	want := "\tfile under edit: Achillcs\n\t                       ^\n" +
+		"\tsource file:     Achilles\n\t                       ^\n" +
+		"\tedit distance 1, a slip of a letter or two\n\n"
+	if got != want {
+		t.Errorf("\ngot:  %q\nwant: %q", got, want)
+	}
+
+	if wordDiff("Achilles", "Achilles") != "" {
+		t.Errorf("identical words should have no diff")
+	}
+
+	colorFlag = "always"
+	if got := wordDiff("Achillcs", "Achilles"); !strings.Contains(got, "Achill"+red+"c"+reset+"s") || !strings.Contains(got, "Achill"+green+"e"+reset+"s") {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,83 @@
+package poweredit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// input reads whitespace separated tokens on its own goroutine, so that waiting
+// for the next command can also be interrupted by a signal. Reading starts with the
+// first call to next, leaving r alone for commands that don't read tokens, such as rpc
+type input struct {
+	r       io.Reader
+	start   sync.Once
+	tokens  chan string
+	signals chan os.Signal
+	script  bool // tokens come from a script rather than being typed, so are echoed
+}
+
+// hangup is reported by next when input ends, eg. when the terminal is closed
+type hangup struct{}
+
+func (hangup) String() string { return "end of input" }
+func (hangup) Signal()        {}
+
+// newInput reads tokens from r. A script can have comments, from a # to the end of the line
+func newInput(r io.Reader, script bool) *input {
+	return &input{
+		r:       r,
+		tokens:  make(chan string),
+		signals: make(chan os.Signal, 1),
+		script:  script,
+	}
+}
+
+func (in *input) read() {
+	scanner := bufio.NewScanner(in.r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if at := strings.IndexByte(line, '#'); at >= 0 && in.script {
+			line = line[:at]
+		}
+		for _, token := range strings.Fields(line) {
+			in.tokens <- token
+		}
+	}
+	close(in.tokens)
+}
+
+// isTerminal tells whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// catchSignals routes SIGINT and SIGHUP to next, rather than letting them end the process
+func (in *input) catchSignals() {
+	signal.Notify(in.signals, os.Interrupt, syscall.SIGHUP)
+}
+
+// next returns the next token, or the signal which arrived while waiting for it.
+// Once input has ended every call returns hangup
+func (in *input) next() (string, os.Signal) {
+	in.start.Do(func() { go in.read() })
+
+	select {
+	case token, ok := <-in.tokens:
+		if !ok {
+			return "", hangup{}
+		}
+		if in.script {
+			fmt.Println(token)
+		}
+		return token, nil
+	case sig := <-in.signals:
+		return "", sig
+	}
+}
@@ -0,0 +1,316 @@
+package poweredit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"poweredit/editingjob"
+	"poweredit/session"
+	"strings"
+	"sync"
+)
+
+// openJobs holds a session, and the job's lock, for each job opened by a frontend other than
+// the terminal, such as the web UI or an editor over JSON-RPC
+type openJobs struct {
+	mu       sync.Mutex
+	sessions map[string]*session.Session
+	locks    map[string]*editingjob.Lock
+	log      io.Writer // where saves on closing and autosave failures are reported
+}
+
+func newOpenJobs(log io.Writer) *openJobs {
+	return &openJobs{sessions: map[string]*session.Session{}, locks: map[string]*editingjob.Lock{}, log: log}
+}
+
+// jobView is a job as listed on the jobs dashboard
+type jobView struct {
+	Name    string   `json:"name"`
+	Edit    string   `json:"edit"`
+	Source  string   `json:"source"`
+	I       int      `json:"i"`
+	J       int      `json:"j"`
+	Saves   int      `json:"saves"`
+	Open    bool     `json:"open"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// sessionView is the state of an open session, and its discrepancy if it has one
+type sessionView struct {
+	Job      string    `json:"job"`
+	ReadOnly bool      `json:"readOnly"`
+	Closed   bool      `json:"closed"`
+	Done     bool      `json:"done"` // no discrepancies left
+	Unsaved  int       `json:"unsaved"`
+	Left     int       `json:"left"`
+	Counting bool      `json:"counting"`
+	Chapter  string    `json:"chapter,omitempty"`
+	Mismatch string    `json:"mismatch,omitempty"`
+	Edit     *wordView `json:"edit,omitempty"`
+	Source   *wordView `json:"source,omitempty"`
+	Distance int       `json:"distance"`
+	Verdict  string    `json:"verdict,omitempty"`
+	Message  string    `json:"message,omitempty"`
+}
+
+// wordView is one side of a discrepancy. Marks are the characters of the word that differ,
+// with one more for a gap at the end. Offset and End are byte offsets of the word in the file at
+// Path, in its own encoding and line endings, as long as the session has no unsaved resolutions;
+// after those they are where the word will be once saved. Line and Col are counted from 1,
+// columns in characters
+type wordView struct {
+	File   string `json:"file"`
+	Path   string `json:"path"`
+	Index  int    `json:"index"`
+	Offset int    `json:"offset"`
+	End    int    `json:"end"`
+	Before string `json:"before"`
+	Word   string `json:"word"`
+	After  string `json:"after"`
+	Marks  []bool `json:"marks"`
+	Line   int    `json:"line"`
+	Col    int    `json:"col"`
+	Page   int    `json:"page,omitempty"`
+	OCR    string `json:"ocr,omitempty"`
+}
+
+// jobParams are the parameters of the methods: the job, and for resolve the command and the
+// word for me. close saves first if save is true
+type jobParams struct {
+	Job     string `json:"job"`
+	Command string `json:"command"`
+	Word    string `json:"word"`
+	Save    bool   `json:"save"`
+}
+
+// jobMethods are what can be done to jobs through do
+var jobMethods = []string{"jobs", "open", "discrepancy", "resolve", "save", "close"}
+
+// do carries out one of jobMethods: list the jobs, or open a job, see its discrepancy, resolve
+// it, save the job or close it, giving the session's state after
+func (oj *openJobs) do(method string, params jobParams) (any, error) {
+	if method == "jobs" {
+		return oj.list()
+	}
+
+	oj.mu.Lock()
+	defer oj.mu.Unlock()
+
+	if method == "open" {
+		if err := oj.open(params.Job); err != nil {
+			return nil, err
+		}
+	}
+
+	s, ok := oj.sessions[params.Job]
+	if !ok {
+		return nil, fmt.Errorf("%s is not open", params.Job)
+	}
+
+	message := ""
+	switch method {
+	case "open", "discrepancy":
+	case "resolve":
+		closed, err := oj.resolve(params.Job, s, params)
+		switch {
+		case errors.Is(err, session.ErrNoChapter):
+			message = err.Error()
+		case err != nil:
+			return nil, err
+		case closed:
+			return sessionView{Job: params.Job, Closed: true}, nil
+		}
+	case "save":
+		if err := s.Save(editingjob.HistorySaved); err != nil {
+			return nil, err
+		}
+		message = "saved"
+	case "close":
+		oj.close(params.Job, params.Save)
+		return sessionView{Job: params.Job, Closed: true}, nil
+	default:
+		return nil, fmt.Errorf("no method %s", method)
+	}
+
+	view := viewOf(params.Job, s)
+	view.Message = message
+	return view, nil
+}
+
+// list lists the jobs in the store. Jobs that aren't open are read, and their originals
+// fingerprinted, without holding the lock on the open sessions
+func (oj *openJobs) list() ([]jobView, error) {
+	names, err := store.Jobs()
+	if err != nil {
+		return nil, err
+	}
+
+	oj.mu.Lock()
+	open := map[string]jobView{}
+	for name, s := range oj.sessions {
+		open[name] = jobViewOf(name, s.Job, true)
+	}
+	oj.mu.Unlock()
+
+	jobs := []jobView{}
+	for _, name := range names {
+		if view, ok := open[name]; ok {
+			jobs = append(jobs, view)
+			continue
+		}
+		job, err := loadJob(name)
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, jobViewOf(name, job, false))
+	}
+
+	return jobs, nil
+}
+
+func jobViewOf(name string, job *editingjob.EditingJob, open bool) jobView {
+	return jobView{
+		Name:    name,
+		Edit:    path.Base(job.LatestEditFile()),
+		Source:  path.Base(job.LatestSrceFile()),
+		I:       job.LastEditingIndex,
+		J:       job.LastSourceIndex,
+		Saves:   len(job.History()) - 1,
+		Open:    open,
+		Changed: job.ChangedOriginals(),
+	}
+}
+
+// open starts a session of a job, read-only if another session has it locked
+func (oj *openJobs) open(name string) error {
+	if _, ok := oj.sessions[name]; ok {
+		return nil
+	}
+
+	job, err := loadJob(name)
+	if err != nil {
+		return err
+	}
+
+	lock, err := job.Lock()
+	var locked *editingjob.LockedError
+	if err != nil && !errors.As(err, &locked) {
+		return err
+	}
+
+	s, err := session.New(job, -1, -1)
+	if err != nil {
+		if lock != nil {
+			lock.Release()
+		}
+		return err
+	}
+	s.ReadOnly = lock == nil
+	s.Log = oj.log
+	s.Next()
+
+	oj.sessions[name] = s
+	if lock != nil {
+		oj.locks[name] = lock
+	}
+	return nil
+}
+
+// resolve applies a resolution command to the discrepancy of a session and moves on to the next,
+// reporting whether v or q closed the session. v and q can be given once no discrepancies are left
+func (oj *openJobs) resolve(name string, s *session.Session, res jobParams) (bool, error) {
+	decision, err := session.Command(res.Command)
+	if err != nil {
+		return false, err
+	}
+
+	switch decision.Action {
+	case session.Save:
+		if err := s.Save(editingjob.HistorySaved); err != nil {
+			return false, err
+		}
+		oj.close(name, false)
+		return true, nil
+	case session.Quit:
+		oj.close(name, false)
+		return true, nil
+	}
+
+	if _, ok := s.Next(); !ok {
+		return false, fmt.Errorf("no discrepancies left")
+	}
+
+	if decision.Action == session.Custom {
+		if res.Word == "" {
+			return false, fmt.Errorf("me needs a word to edit both by")
+		}
+		decision.Word = res.Word
+	}
+
+	if err := s.Apply(decision); err != nil {
+		return false, err
+	}
+	s.Next()
+	return false, nil
+}
+
+// close ends the session of a job, saving it first if asked to and there's anything to save
+func (oj *openJobs) close(name string, save bool) {
+	s := oj.sessions[name]
+	if unsaved := s.Unsaved(); save && !s.ReadOnly && unsaved > 0 {
+		if err := s.Save(editingjob.HistorySaved); err != nil {
+			fmt.Fprintf(oj.log, "couldn't save %s, the job is still at its previous edition: %v\n", name, err)
+		} else {
+			fmt.Fprintf(oj.log, "%s: %d unsaved resolutions have been saved\n", name, unsaved)
+		}
+	}
+	if lock, ok := oj.locks[name]; ok {
+		lock.Release()
+	}
+	delete(oj.sessions, name)
+	delete(oj.locks, name)
+}
+
+func (oj *openJobs) closeAll() {
+	oj.mu.Lock()
+	defer oj.mu.Unlock()
+	for name := range oj.sessions {
+		oj.close(name, true)
+	}
+}
+
+func viewOf(name string, s *session.Session) sessionView {
+	view := sessionView{Job: name, ReadOnly: s.ReadOnly, Unsaved: s.Unsaved()}
+	view.Left, view.Counting = s.Left()
+	view.Counting = !view.Counting
+
+	d, ok := s.Next()
+	if !ok {
+		view.Done = true
+		return view
+	}
+
+	c := compareWords(d.EditWord.W, d.SourceWord.W)
+	view.Distance, view.Verdict = c.distance, c.verdict
+
+	if d.EditChapter != d.SourceChapter {
+		view.Mismatch = fmt.Sprintf("file under edit is in %s, source file in %s", s.ChapterTitle(d.EditChapter), s.ChapterTitle(d.SourceChapter))
+	} else {
+		view.Chapter = s.Anchors()[d.EditChapter].Title
+	}
+
+	view.Edit = &wordView{File: path.Base(s.Job.LatestEditFile()), Index: d.Edit, Word: d.EditWord.W, Marks: c.inEdit,
+		Before: s.Edit.Excerpt(d.Edit-10, d.Edit), After: s.Edit.Excerpt(d.Edit+1, d.Edit+11),
+		Page: d.EditWord.Page(), OCR: strings.TrimSpace(ocrLine("file under edit", d.EditWord))}
+	view.Edit.Path, view.Edit.Offset, view.Edit.End = s.Job.LatestEditFile(), s.Edit.FileOffset(d.Edit), s.Edit.FileEnd(d.Edit)
+	view.Edit.Line, view.Edit.Col = s.Edit.Position(d.Edit)
+
+	view.Source = &wordView{File: path.Base(s.Job.LatestSrceFile()), Index: d.Source, Word: d.SourceWord.W, Marks: c.inSource,
+		Before: s.Source.Excerpt(d.Source-10, d.Source), After: s.Source.Excerpt(d.Source+1, d.Source+11),
+		Page: d.SourceWord.Page(), OCR: strings.TrimSpace(ocrLine("source file", d.SourceWord))}
+	view.Source.Path, view.Source.Offset, view.Source.End = s.Job.LatestSrceFile(), s.Source.FileOffset(d.Source), s.Source.FileEnd(d.Source)
+	view.Source.Line, view.Source.Col = s.Source.Position(d.Source)
+
+	return view
+}
@@ -0,0 +1,45 @@
+package poweredit
+
+import (
+	"io"
+	"testing"
+)
+
+func TestResolveWhenDone(t *testing.T) {
+	for _, command := range []string{"v", "q"} {
+		t.Run(command, func(t *testing.T) {
+			job := newTestJob(t)
+			editions := len(job.History())
+			oj := newOpenJobs(io.Discard)
+
+			if _, err := oj.do("open", jobParams{Job: job.Name()}); err != nil {
+				t.Fatalf("open resulted in error: %v", err)
+			}
+			for n := 0; n < 2; n++ {
+				if _, err := oj.do("resolve", jobParams{Job: job.Name(), Command: "e"}); err != nil {
+					t.Fatalf("resolve resulted in error: %v", err)
+				}
+			}
+			view, err := oj.do("discrepancy", jobParams{Job: job.Name()})
+			if err != nil || !view.(sessionView).Done {
+				t.Fatalf("got %+v, %v, want no discrepancies left", view, err)
+			}
+
+			view, err = oj.do("resolve", jobParams{Job: job.Name(), Command: command})
+			if err != nil {
+				t.Fatalf("%s with no discrepancies left resulted in error: %v", command, err)
+			}
+			if !view.(sessionView).Closed {
+				t.Errorf("%s didn't close the job: %+v", command, view)
+			}
+
+			resumed, err := loadJob(job.Name())
+			if err != nil {
+				t.Fatalf("couldn't read job: %v", err)
+			}
+			if saved := len(resumed.History()) > editions; saved != (command == "v") {
+				t.Errorf("got saved %v after %s", saved, command)
+			}
+		})
+	}
+}
@@ -2,47 +2,111 @@ package poweredit
 
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
 	"poweredit/editingjob"
+	"poweredit/session"
 	"poweredit/textwords"
-	"poweredit/utils"
+	"slices"
+	"strconv"
 	"strings"
 )
 
 var jobfile string
 var editIndexFlag int
 var sourceIndexFlag int
+var homeFlag string
+var readOnlyFlag bool
+var autosaveFlag int
+var onInterruptFlag string
+var rewrapFlag int
+var colorFlag string
+var scriptFlag string
+var addrFlag string
+
+var store *editingjob.Store
 
 var jobdata *editingjob.EditingJob
+var jobLock *editingjob.Lock
+
+var in *input
 
 func init() {
 	flag.IntVar(&editIndexFlag, "ei", -1, "editing index (ei) - location to start edit comparison in editing file")
 	flag.IntVar(&sourceIndexFlag, "si", -1, "source index (si) - location to start edit comparison in source file")
+	flag.StringVar(&homeFlag, "home", "", "directory to keep jobs in, defaults to $POWEREDIT_HOME or $XDG_DATA_HOME/poweredit")
+	flag.BoolVar(&readOnlyFlag, "readonly", false, "open the job without locking it; changes can't be saved")
+	flag.IntVar(&autosaveFlag, "autosave", -1, "save the session every n resolutions, 0 to turn off; defaults to the job's autosave setting")
+	flag.IntVar(&rewrapFlag, "rewrap", -1, "on save, rewrap changed paragraphs of the file under edit to n columns, 0 to turn off; defaults to the job's rewrap setting")
+	flag.StringVar(&onInterruptFlag, "on-interrupt", "", "on ctrl-c, 'save' the session or 'ask' before throwing work away; defaults to the job's on-interrupt setting, else ask")
+	flag.StringVar(&colorFlag, "color", "auto", "highlight the characters that differ at a discrepancy in color: 'always', 'never', or 'auto' for when writing to a terminal")
+	flag.StringVar(&scriptFlag, "script", "", "read resolution commands from a file rather than the terminal, with plain output")
+	flag.StringVar(&addrFlag, "addr", "localhost:7070", "address for `poweredit serve` to listen on")
 }
 
 func initJob() {
 	args := flag.Args()
 	argln := len(args)
 
+	if argln > 0 {
+		switch args[0] {
+		case "history", "diff", "rollback":
+			if err := runHistoryCommand(args); err != nil {
+				fmt.Println(err)
+			}
+			os.Exit(0)
+		case "migrate":
+			if err := runMigrateCommand(args); err != nil {
+				fmt.Println(err)
+			}
+			os.Exit(0)
+		case "unlock":
+			if err := runUnlockCommand(args); err != nil {
+				fmt.Println(err)
+			}
+			os.Exit(0)
+		case "set":
+			if err := runSetCommand(args); err != nil {
+				fmt.Println(err)
+			}
+			os.Exit(0)
+		case "report":
+			if err := runReportCommand(args); err != nil {
+				fmt.Println(err)
+			}
+			os.Exit(0)
+		case "serve":
+			if err := runServeCommand(args); err != nil {
+				fmt.Println(err)
+			}
+			os.Exit(0)
+		case "rpc":
+			if err := runRPCCommand(args); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			os.Exit(0)
+		}
+	}
+
 	if argln == 1 {
 
 		if args[0] == "jobs" {
-			utils.ClearScreen()
+			clearScreen()
 			fmt.Printf("All available jobs:\n\n")
-			err := editingjob.DisplayJobs()
+			err := store.DisplayJobs()
 			if err != nil {
 				fmt.Println(err)
 			}
 			os.Exit(0)
 		}
 	
-		if strings.HasSuffix(args[0], ".csv") {
+		if isJobFile(args[0]) {
 			jobfile = args[0]
-			job, err := editingjob.FromJobFile(jobfile)
+			job, err := store.FromJobFile(jobfile)
 			if err != nil {
 				fmt.Printf("Couldn't locate jobfile %s\n%v", jobfile, err)
 				os.Exit(0)
@@ -51,11 +115,11 @@ func initJob() {
 			return
 		}
 
-		validJob, _ := editingjob.JobExists(args[0])
+		validJob, _ := store.JobExists(args[0])
 
 		if (validJob) {
-			jobfile = args[0]+".csv"
-			job, err := editingjob.FromJobFile(jobfile)
+			jobfile = args[0]+".json"
+			job, err := store.FromJobFile(jobfile)
 			if err != nil {
 				fmt.Printf("Couldn't locate jobfile %s\n%v", jobfile, err)
 				os.Exit(0)
@@ -75,7 +139,7 @@ func initJob() {
 			fmt.Printf("tried to create new job with %s source file but could not find absolute path to the file: %v", args[1], err)
 		}
 		
-		job, err := editingjob.FromEditAndSourceFiles(newEditingFile, newSourceFile)
+		job, err := store.FromEditAndSourceFiles(newEditingFile, newSourceFile)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(0)
@@ -89,6 +153,234 @@ func initJob() {
 
 }
 
+func loadJob(arg string) (*editingjob.EditingJob, error) {
+	if isJobFile(arg) {
+		return store.FromJobFile(arg)
+	}
+
+	validJob, err := store.JobExists(arg)
+	if err != nil {
+		return nil, err
+	}
+	if !validJob {
+		return nil, fmt.Errorf("no job named %s, use `poweredit jobs` to list jobs", arg)
+	}
+
+	return store.FromJobFile(arg + ".json")
+}
+
+//	a job manifest, or a CSV job file from before manifests which will be migrated
+func isJobFile(arg string) bool {
+	return strings.HasSuffix(arg, ".json") || strings.HasSuffix(arg, ".csv")
+}
+
+//	set <job> [setting [value]] - list the job's settings, or change one; an empty value removes it
+func runSetCommand(args []string) error {
+	if len(args) < 2 || len(args) > 4 {
+		return fmt.Errorf("usage: poweredit set <job> [setting [value]]")
+	}
+
+	job, err := loadJob(args[1])
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 2 {
+		for _, key := range jobSettings {
+			fmt.Printf("%-12s %s\n", key, job.Setting(key))
+		}
+		return nil
+	}
+
+	if !slices.Contains(jobSettings, args[2]) {
+		return fmt.Errorf("unknown setting %s, settings are: %s", args[2], strings.Join(jobSettings, ", "))
+	}
+
+	value := ""
+	if len(args) == 4 {
+		value = args[3]
+	}
+	if args[2] == "on-interrupt" && value != "" {
+		if err := checkOnInterrupt(value); err != nil {
+			return err
+		}
+	}
+
+	lock, err := job.Lock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	job.SetSetting(args[2], value)
+	return job.SaveManifest()
+}
+
+//	settings kept in a job's manifest which `poweredit set` can change
+var jobSettings = []string{
+	"autosave",
+	"on-interrupt",
+	"boilerplate",
+	"rewrap",
+	"markup",
+	"punctuation",
+}
+
+//	what to do on ctrl-c, for the on-interrupt setting and --on-interrupt
+var onInterruptValues = []string{"save", "ask"}
+
+func checkOnInterrupt(value string) error {
+	if !slices.Contains(onInterruptValues, value) {
+		return fmt.Errorf("unknown on-interrupt %s, it can be: %s", value, strings.Join(onInterruptValues, ", "))
+	}
+	return nil
+}
+
+//	jobSettingString reads a setting, preferring a non-empty flag value over the job's setting
+func jobSettingString(flagValue, key, otherwise string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if value := jobdata.Setting(key); value != "" {
+		return value
+	}
+	return otherwise
+}
+
+//	unlock <job> - remove a lock left by a session that can't be checked, eg. one on another host
+func runUnlockCommand(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: poweredit unlock <job>")
+	}
+
+	job, err := loadJob(args[1])
+	if err != nil {
+		return err
+	}
+
+	holder, err := job.LockHolder()
+	if err != nil {
+		return err
+	}
+	if holder == nil {
+		fmt.Printf("%s is not locked\n", args[1])
+		return nil
+	}
+
+	if !holder.Stale() {
+		fmt.Println(&editingjob.LockedError{Job: args[1], Holder: *holder})
+		if !confirm("remove the lock anyway? only do this if that session has ended (y/n): ") {
+			return nil
+		}
+	}
+
+	if err := job.Unlock(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s unlocked\n", args[1])
+	return nil
+}
+
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	answer, sig := in.next()
+	return sig == nil && strings.ToLower(answer) == "y"
+}
+
+// exit releases the job's lock, which deferred calls won't do when exiting with os.Exit
+func exit(code int) {
+	if jobLock != nil {
+		jobLock.Release()
+	}
+	os.Exit(code)
+}
+
+//	migrate [job] - move editions out of the shared text directory into each job's directory
+func runMigrateCommand(args []string) error {
+	if len(args) == 1 {
+		return store.MigrateAllTexts()
+	}
+
+	job, err := loadJob(args[1])
+	if err != nil {
+		return err
+	}
+
+	lock, err := job.Lock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	copied, err := job.MigrateTexts()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d files copied into the job directory\n", args[1], copied)
+	return nil
+}
+
+//	history <job>, diff <job> <a> <b> and rollback <job> <n>
+func runHistoryCommand(args []string) error {
+	usage := fmt.Errorf("usage: poweredit history <job> | poweredit diff <job> <edition> <edition> | poweredit rollback <job> <edition>")
+
+	if len(args) < 2 {
+		return usage
+	}
+
+	job, err := loadJob(args[1])
+	if err != nil {
+		return err
+	}
+
+	editions := []int{}
+	for _, arg := range args[2:] {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("%s is not an edition number", arg)
+		}
+		editions = append(editions, n)
+	}
+
+	switch {
+	case args[0] == "history" && len(editions) == 0:
+		fmt.Printf("Editions of %s:\n\n", args[1])
+		return job.DisplayEditions()
+	case args[0] == "diff" && len(editions) == 2:
+		return job.DiffEditions(os.Stdout, editions[0], editions[1])
+	case args[0] == "rollback" && len(editions) == 1:
+		lock, err := job.Lock()
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
+		if err := job.Rollback(editions[0]); err != nil {
+			return err
+		}
+		fmt.Printf("%s rolled back to edition %d\n\n\tleft at indexes [i = %d] [j = %d]\n", args[1], editions[0], job.LastEditingIndex, job.LastSourceIndex)
+		return nil
+	}
+
+	return usage
+}
+
+//	report <job> - list the discrepancies left in a job, citing source pages where they're known
+func runReportCommand(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: poweredit report <job>")
+	}
+
+	job, err := loadJob(args[1])
+	if err != nil {
+		return err
+	}
+
+	return job.Report(os.Stdout)
+}
+
 func Run(args []string) {
 
 	
@@ -98,6 +390,39 @@ func Run(args []string) {
 	************************************************************************ */
 	flag.Parse()
 
+	if onInterruptFlag != "" {
+		if err := checkOnInterrupt(onInterruptFlag); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	//	commands from a script, or piped in, are echoed and the screen isn't cleared, so the
+	//	output reads as a plain transcript of the session
+	if scriptFlag != "" {
+		script, err := os.Open(scriptFlag)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer script.Close()
+		in = newInput(script, true)
+	} else {
+		in = newInput(os.Stdin, !isTerminal(os.Stdin))
+	}
+
+	root, err := editingjob.ResolveRoot(homeFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	store, err = editingjob.NewStore(root)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	
 
 	/* ************************************************************************
@@ -108,177 +433,161 @@ func Run(args []string) {
 	
 
 	/* ************************************************************************
-		SET STARTING INDEXES FOR EDITING JOB
+		LOCK THE JOB SO NO OTHER SESSION CAN SAVE OVER THIS ONE
 	************************************************************************ */
-	var i int
-	var j int
-
-	if editIndexFlag >= 0 {
-		i = editIndexFlag
-	} else {
-		i = jobdata.LastEditingIndex
+	readOnly := readOnlyFlag
+
+	if !readOnly {
+		lock, err := jobdata.Lock()
+		var locked *editingjob.LockedError
+		if errors.As(err, &locked) {
+			fmt.Println(locked)
+			if !confirm("open a read-only view of the job instead? (y/n): ") {
+				os.Exit(0)
+			}
+			readOnly = true
+		} else if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		} else {
+			jobLock = lock
+			defer lock.Release()
+		}
 	}
 
-	if sourceIndexFlag >= 0 {
-		j = sourceIndexFlag
-	} else {
-		j = jobdata.LastSourceIndex
-	}
+	
 
 	/* ************************************************************************
-		CREATE TEXTWORDS STRUCTS FOR EDIT AND SOURCE FILES
+		WARN IF THE ORIGINALS HAVE CHANGED SINCE THE JOB WAS CREATED
 	************************************************************************ */
-		editWords, err := textwords.FromFile(jobdata.LatestEditFile())
-		if err != nil {
-			fmt.Println("Error getting edit words:", err)
-			return
+	if changed := jobdata.ChangedOriginals(); len(changed) > 0 {
+		fmt.Printf("\nThese files have changed since the job was created, eg. by an upstream re-release:\n\n")
+		for _, original := range changed {
+			fmt.Printf("\t%s\n", original)
 		}
+		fmt.Println()
 
-		sourceWords, err := textwords.FromFile(jobdata.LatestSrceFile())
-		if err != nil {
-			fmt.Println("Error getting source words:", err)
-			return
+		if readOnly {
+			fmt.Printf("continuing with the job's copies\n\n")
+		} else if confirm("re-apply the job's decisions to the new versions? (y/n): ") {
+			results, err := jobdata.Reapply()
+			if err != nil {
+				fmt.Println("Couldn't re-apply the job's decisions:", err)
+				exit(1)
+			}
+			for _, result := range results {
+				fmt.Printf("re-applied to %s", result.Original)
+				if result.Conflicts > 0 {
+					fmt.Printf(", keeping the job's version in %d places the new version also changed", result.Conflicts)
+				}
+				fmt.Println()
+			}
+			if !confirm("continue editing? (y/n): ") {
+				exit(0)
+			}
+		} else {
+			fmt.Printf("continuing with the job's copies, you'll be asked again next time\n\n")
 		}
+	}
+
+	
 
 	/* ************************************************************************
-		ITEREATE OVER TEXTWORDS UNTIL END OF JOB
+		READ BOTH FILES, STARTING WHERE ASKED TO OR WHERE THE JOB LEFT OFF
 	************************************************************************ */
+	sess, err := session.New(jobdata, editIndexFlag, sourceIndexFlag)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
-	minLength := min(editWords.Len(), sourceWords.Len())
-
-	discrepancies := false
-	continueEditing := true
-
-	for (i < minLength || j < minLength) && continueEditing {
-		editWordLoc := editWords.GetWord(i)
-		sourceWordLoc := sourceWords.GetWord(j)
-
-		editWord := utils.ReplaceQuotes(editWordLoc.W)
-		sourceWord := utils.ReplaceQuotes(sourceWordLoc.W)
-
-		if editWord == "" {
-			i++
-			continue
-		}
-		if sourceWord == "" {
-			j++
-			continue
-		}
+	sess.ReadOnly = readOnly
+	sess.Log = os.Stdout
+	if autosaveFlag >= 0 {
+		sess.Autosave = autosaveFlag
+	}
+	if rewrapFlag >= 0 {
+		sess.Rewrap = rewrapFlag
+	}
 
-		if editWord != sourceWord {
-			discrepancies = true
-			printDisplay(jobdata,editWords,sourceWords,i,j)
-			
-			var choice string
-
-			for {
-				printResolutionOptions()
-				fmt.Scan(&choice)
-
-				if choice == "a" {
-					//  discrep is that file under edit is missing a token from the source
-					//  so add source token to the file under edit
-					editWords.Insert(sourceWordLoc, i)
-
-					break
-				} else if choice == "e" {
-					//  discrep is just a mispelled word or the wrong word, but before and after, the text is good
-					//  so make words1[i] equal whatever is at words2[i]
-					editWords.Edit(i, sourceWordLoc)
-					//  resume comparison at i
-					break
-				} else if choice == "ex" {
-					//  e but apply the edit to the source file
-					sourceWords.Edit(j, editWordLoc)
-					//  resume comparison at i
-					break
-				} else if choice == "d" {
-					// surplus token in file under edit, delete that token
-					editWords.Delete(i)
-
-					break
-				} else if choice == "q" {
-					// quit without saving any edits
-					utils.ClearScreen()
-					os.Exit(0)
-				} else if choice == "v" {
-					// save current changes and exit
-					continueEditing = false
-					break
-				} else if choice == "x" {
-					// delete token from source
-					sourceWords.Delete(j)
-					break
-				} else if choice == "me" {
-					var customWord string
-					var confirm string
-					// manually enter word and edit both by this word
-					for {
-						fmt.Print("enter word to edit both by: ")
-						fmt.Scan(&customWord)
-						fmt.Printf("save '%s' to both indexes? (y/n): ", customWord)
-						fmt.Scan(&confirm)
-						if strings.ToLower(confirm) == "y" {
-							break
-						}
-						printDisplay(jobdata,editWords,sourceWords,i,j)
-					}
-
-					editWordLoc.W = customWord
-					sourceWordLoc.W = customWord
-					editWords.Edit(i, editWordLoc)
-					sourceWords.Edit(j, sourceWordLoc)
-					break
-				} else {
-					digits, err := utils.ParseDigits(choice)
-					if err != nil {
-						fmt.Printf("Not a valid command: %s", choice)
-						break
-					}
-
-					if len(digits) == 1 {
-						i += digits[0]
-						break
-					} else {
-						i += digits[0]
-						j += digits[1]
-						break
-					}
-				}
-			}
-		} else {
-			i++
-			j++
-		}
+	/* ************************************************************************
+		RESOLVE EACH DISCREPANCY AT THE TERMINAL UNTIL END OF JOB
+	************************************************************************ */
+	in.catchSignals()
+
+	outcome, err := sess.Run(&terminal{onInterrupt: jobSettingString(onInterruptFlag, "on-interrupt", "ask")})
+
+	switch {
+	case err != nil:
+		fmt.Println(err)
+	case outcome == session.Quitted:
+		clearScreen()
+		exit(0)
+	case outcome == session.NotSaved:
+		fmt.Println("Read-only view, changes have not been saved.")
+	case outcome == session.Saved:
+		fmt.Println("Files have been updated based on user choices.")
+	default:
+		fmt.Println("Files are identical.")
 	}
 
-	if discrepancies {
-		if err := jobdata.SaveLatestEditAndSourceChanges(editWords.Text(), sourceWords.Text()); err != nil {
-		// if err := utils.UpdateFile(jobdata.LatestEditFile(), editWords.Text()); err != nil {
-			fmt.Printf("Error updating %s: %v", jobdata.LatestEditFile(), err)
-		}
+	fmt.Printf("\n\nleft at indexes [i = %d] [j = %d]\n\n", sess.I, sess.J)
 
-		jobdata.LastEditingIndex = i
-		jobdata.LastSourceIndex = j
+	if shared, _ := jobdata.UsesSharedTexts(); shared {
+		fmt.Printf("note: this job keeps editions in %s, where jobs with same named files overwrite each other\n"+
+			"run `poweredit migrate` to move them into the job's own directory\n\n", store.TextDirectory)
+	}
+}
 
-		jobdata.UpdateEditingJob()
 
-		fmt.Println("Files have been updated based on user choices.")
 
+func printDisplay(s *session.Session, d session.Discrepancy) {
+	display(fmt.Sprintf("\n\tediting %s  by  %s\n\n\n", path.Base(s.Job.LatestEditFile()), path.Base(s.Job.LatestSrceFile())))
+	fmt.Printf("\tDISCREPANCY:\n\n\tfile under edit: %s\n\tsource file:     %s\n\n", s.Edit.SurroundingText(d.Edit, 10), s.Source.SurroundingText(d.Source, 10))
+	fmt.Print(wordDiff(d.EditWord.W, d.SourceWord.W))
+	editLine, editCol := s.Edit.Position(d.Edit)
+	sourceLine, sourceCol := s.Source.Position(d.Source)
+	fmt.Printf("\tfile under edit at line %d:%d, source file at line %d:%d\n\n", editLine, editCol, sourceLine, sourceCol)
+	if d.EditChapter != d.SourceChapter {
+		fmt.Printf("\tfile under edit is in %s, source file in %s\n"+
+			"\tchapters are compared on their own, so add or delete the words left in one, or n to go on to the next chapter\n\n",
+			s.ChapterTitle(d.EditChapter), s.ChapterTitle(d.SourceChapter))
+	} else if title := s.Anchors()[d.EditChapter].Title; title != "" {
+		fmt.Printf("\tin %s\n\n", title)
+	}
+	if left, done := s.Left(); done {
+		fmt.Printf("\tabout %d discrepancies left\n\n", left)
 	} else {
-		fmt.Println("Files are identical.")
+		fmt.Printf("\tat least %d discrepancies left, still counting\n\n", left)
 	}
-
-	fmt.Printf("\n\nleft at indexes [i = %d] [j = %d]\n\n", i, j)
+	if page := d.SourceWord.Page(); page > 0 {
+		fmt.Printf("\tsource page %d\n\n", page)
+	}
+	fmt.Printf("%s%s\n\n\n\n\n\n\n\n\n\n\n\n", ocrLine("file under edit", d.EditWord), ocrLine("source file", d.SourceWord))
 }
 
+//	ocrLine describes what the OCR engine recorded about a word read from hOCR or ALTO.
+//	A low confidence source word is likely the wrong one, pointing to e over ex
+func ocrLine(file string, wl textwords.WordLoc) string {
+	ocr := wl.OCR()
+	if ocr == nil {
+		return ""
+	}
 
+	confidence := "unknown"
+	if ocr.Confidence >= 0 {
+		confidence = fmt.Sprintf("%.0f%%", ocr.Confidence*100)
+		if ocr.Confidence < lowConfidence {
+			confidence += " (low)"
+		}
+	}
 
-func printDisplay(job *editingjob.EditingJob, ew, sw *textwords.TextWords, i, j int) {
-	utils.Display(fmt.Sprintf("\n\tediting %s  by  %s\n\n\n", path.Base(job.LatestEditFile()), path.Base(job.LatestSrceFile())))
-	fmt.Printf("\tDISCREPANCY:\n\n\tfile under edit: %s\n\tsource file:     %s\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n", ew.SurroundingText(i, 10), sw.SurroundingText(j, 10))
+	return fmt.Sprintf("\tOCR confidence of %s word '%s': %s, line %d\n", file, wl.W, confidence, ocr.Line)
 }
 
+//	below this confidence an OCR word is flagged as likely wrong
+const lowConfidence = 0.6
+
 func printResolutionOptions() {
 	fmt.Printf(
 		"\tHow to resolve?\n" +
@@ -290,6 +599,8 @@ func printResolutionOptions() {
 			"\tme - manually enter a custom word set current token for file under edit and source file to this word\n" +
 			"\td - delete token from file under edit\n" +
 			"\tx - delete current token from source file\n" +
+			"\tn - go on to the start of the next chapter in both files\n" +
+			"\tp - go back to the start of the previous chapter in both files\n" +
 			"\tv - save changes and quit\n" +
 			"\tq - quit without saving any changes made\n\n\tenter selection: ")
 }
\ No newline at end of file
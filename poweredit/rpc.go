@@ -0,0 +1,171 @@
+package poweredit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcNoMethod       = -32601
+	rpcInvalidParams  = -32602
+	rpcFailed         = -32000 // the method was called but couldn't be carried out
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// rpc - JSON-RPC 2.0 over stdin and stdout, one message per line, for editor integrations
+func runRPCCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: poweredit rpc")
+	}
+
+	//	stdout carries the responses, so anything else goes to stderr
+	oj := newOpenJobs(os.Stderr)
+
+	//	save what's been resolved and let go of the jobs if the editor goes away without closing them
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGHUP, syscall.SIGTERM)
+	go func() {
+		<-signals
+		oj.closeAll()
+		os.Exit(0)
+	}()
+	defer oj.closeAll()
+
+	return serveRPC(oj, os.Stdin, os.Stdout)
+}
+
+// serveRPC answers each request read from r on w, until r ends. A batch, an array of requests
+// on one line, is answered with an array of their responses
+func serveRPC(oj *openJobs, r io.Reader, w io.Writer) error {
+	out := json.NewEncoder(w)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var reply any
+		if line[0] != '[' {
+			if resp := oj.answer(line); resp != nil {
+				reply = resp
+			}
+		} else {
+			var batch []json.RawMessage
+			switch {
+			case json.Unmarshal(line, &batch) != nil:
+				reply = errorResponse(&rpcError{Code: rpcParseError, Message: "couldn't parse request"})
+			case len(batch) == 0:
+				reply = errorResponse(&rpcError{Code: rpcInvalidRequest, Message: "empty batch"})
+			default:
+				resps := []*rpcResponse{}
+				for _, msg := range batch {
+					if resp := oj.answer(msg); resp != nil {
+						resps = append(resps, resp)
+					}
+				}
+				if len(resps) > 0 {
+					reply = resps
+				}
+			}
+		}
+
+		//	notifications, without an id, get no response
+		if reply == nil {
+			continue
+		}
+		if err := out.Encode(reply); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// answer carries out a request, giving its response, or nil for a notification
+func (oj *openJobs) answer(msg json.RawMessage) *rpcResponse {
+	var req rpcRequest
+	var result any
+	var err error
+	if json.Unmarshal(msg, &req) != nil {
+		err = &rpcError{Code: rpcParseError, Message: "couldn't parse request"}
+		if json.Valid(msg) {
+			err = &rpcError{Code: rpcInvalidRequest, Message: "not a JSON-RPC 2.0 request"}
+		}
+	} else if req.JSONRPC != "2.0" || req.Method == "" {
+		err = &rpcError{Code: rpcInvalidRequest, Message: "not a JSON-RPC 2.0 request"}
+	} else {
+		result, err = oj.call(req.Method, req.Params)
+	}
+
+	if req.ID == nil && req.Method != "" && req.JSONRPC == "2.0" {
+		return nil
+	}
+
+	resp := &rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+	if req.ID == nil {
+		resp.ID = json.RawMessage("null")
+	}
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: rpcFailed, Message: err.Error()}
+		}
+		resp.Result, resp.Error = nil, rpcErr
+	}
+	return resp
+}
+
+func errorResponse(err *rpcError) *rpcResponse {
+	return &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: err}
+}
+
+// call decodes the parameters of a method and carries it out
+func (oj *openJobs) call(method string, raw json.RawMessage) (any, error) {
+	if !slices.Contains(jobMethods, method) {
+		return nil, &rpcError{Code: rpcNoMethod, Message: fmt.Sprintf("no method %s", method)}
+	}
+
+	var params jobParams
+	if raw != nil && json.Unmarshal(raw, &params) != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "params must be an object"}
+	}
+	if params.Job == "" && method != "jobs" {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "params need the job"}
+	}
+
+	return oj.do(method, params)
+}
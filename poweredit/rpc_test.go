@@ -0,0 +1,188 @@
+package poweredit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"poweredit/editingjob"
+	"strings"
+	"testing"
+)
+
+// rpcSession sends requests to serveRPC, one a line, giving each line of its output
+func rpcSession(t *testing.T, requests ...string) []string {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+	if err := serveRPC(newOpenJobs(io.Discard), strings.NewReader(strings.Join(requests, "\n")), out); err != nil {
+		t.Fatalf("serveRPC resulted in error: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+type testResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result sessionView     `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+func decodeResponse(t *testing.T, line string) testResponse {
+	t.Helper()
+
+	var resp testResponse
+	if err := json.Unmarshal([]byte(line), &resp); err != nil {
+		t.Fatalf("couldn't decode response %q: %v", line, err)
+	}
+	return resp
+}
+
+func TestRPC(t *testing.T) {
+	job := newTestJob(t)
+	editions := len(job.History())
+	params := `"params": {"job": "` + job.Name() + `"`
+
+	lines := rpcSession(t,
+		`{"jsonrpc": "2.0", "id": 1, "method": "open", `+params+`}}`,
+		`{"jsonrpc": "2.0", "id": 2, "method": "resolve", `+params+`, "command": "e"}}`,
+		`{"jsonrpc": "2.0", "method": "discrepancy", `+params+`}}`,
+		`{"jsonrpc": "2.0", "id": 3, "method": "save", `+params+`}}`,
+		`{"jsonrpc": "2.0", "id": 4, "method": "resolve", `+params+`, "command": "e"}}`,
+		`{"jsonrpc": "2.0", "id": 5, "method": "resolve", `+params+`, "command": "q"}}`,
+		`{"jsonrpc": "2.0", "id": 6, "method": "discrepancy", `+params+`}}`,
+	)
+	if len(lines) != 6 {
+		t.Fatalf("got %d responses, want 6, none for the notification:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+
+	opened := decodeResponse(t, lines[0])
+	if string(opened.ID) != "1" || opened.Error != nil || opened.Result.Edit.Word != "angr" {
+		t.Errorf("open: got %s", lines[0])
+	}
+	if resolved := decodeResponse(t, lines[1]); resolved.Result.Edit == nil || resolved.Result.Edit.Word != "ils" {
+		t.Errorf("resolve: got %s", lines[1])
+	}
+	if saved := decodeResponse(t, lines[2]); saved.Result.Message != "saved" {
+		t.Errorf("save: got %s", lines[2])
+	}
+	if done := decodeResponse(t, lines[3]); !done.Result.Done {
+		t.Errorf("resolve last discrepancy: got %s", lines[3])
+	}
+	if closed := decodeResponse(t, lines[4]); closed.Error != nil || !closed.Result.Closed {
+		t.Errorf("q with no discrepancies left: got %s", lines[4])
+	}
+	if notOpen := decodeResponse(t, lines[5]); notOpen.Error == nil || notOpen.Error.Code != rpcFailed {
+		t.Errorf("discrepancy after closing: got %s", lines[5])
+	}
+
+	resumed, err := loadJob(job.Name())
+	if err != nil {
+		t.Fatalf("couldn't read job: %v", err)
+	}
+	if len(resumed.History()) != editions+1 {
+		t.Errorf("got %d editions, want the one save, q having thrown away the last resolution", len(resumed.History())-editions)
+	}
+}
+
+func TestRPCErrors(t *testing.T) {
+	job := newTestJob(t)
+
+	var tests = []struct {
+		name    string
+		request string
+		code    int
+	}{
+		{"not JSON", `{"jsonrpc": "2.0", "id": 1, "method"`, rpcParseError},
+		{"not JSON-RPC 2.0", `{"id": 1, "method": "jobs"}`, rpcInvalidRequest},
+		{"no method", `{"jsonrpc": "2.0", "id": 1}`, rpcInvalidRequest},
+		{"not an object", `1`, rpcInvalidRequest},
+		{"unknown method", `{"jsonrpc": "2.0", "id": 1, "method": "rename"}`, rpcNoMethod},
+		{"params not an object", `{"jsonrpc": "2.0", "id": 1, "method": "open", "params": [1]}`, rpcInvalidParams},
+		{"no job", `{"jsonrpc": "2.0", "id": 1, "method": "open", "params": {}}`, rpcInvalidParams},
+		{"no such job", `{"jsonrpc": "2.0", "id": 1, "method": "open", "params": {"job": "nojob"}}`, rpcFailed},
+		{"unknown command", `{"jsonrpc": "2.0", "id": 1, "method": "open", "params": {"job": "` + job.Name() + `"}}` + "\n" +
+			`{"jsonrpc": "2.0", "id": 1, "method": "resolve", "params": {"job": "` + job.Name() + `", "command": "z"}}`, rpcFailed},
+		{"empty batch", `[]`, rpcInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := rpcSession(t, tt.request)
+			resp := decodeResponse(t, lines[len(lines)-1])
+			if resp.Error == nil || resp.Error.Code != tt.code {
+				t.Errorf("got %s, want error %d", lines[len(lines)-1], tt.code)
+			}
+			rpcSession(t, `{"jsonrpc": "2.0", "id": 1, "method": "close", "params": {"job": "`+job.Name()+`"}}`)
+		})
+	}
+}
+
+func TestRPCBatch(t *testing.T) {
+	job := newTestJob(t)
+	params := `"params": {"job": "` + job.Name() + `"`
+
+	lines := rpcSession(t,
+		`[{"jsonrpc": "2.0", "id": 1, "method": "open", `+params+`}}, {"jsonrpc": "2.0", "method": "discrepancy", `+params+`}}, 1, `+
+			`{"jsonrpc": "2.0", "id": "close", "method": "close", `+params+`}}]`,
+		`[{"jsonrpc": "2.0", "method": "jobs"}]`,
+		`[1,`,
+	)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want a response for the batch with requests and a parse error:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+
+	var batch []testResponse
+	if err := json.Unmarshal([]byte(lines[0]), &batch); err != nil {
+		t.Fatalf("batch response %q isn't an array: %v", lines[0], err)
+	}
+	if len(batch) != 3 {
+		t.Fatalf("got %d responses in the batch, want 3, none for the notification: %s", len(batch), lines[0])
+	}
+	if string(batch[0].ID) != "1" || batch[0].Result.Edit == nil {
+		t.Errorf("open in batch: got %+v", batch[0])
+	}
+	if batch[1].Error == nil || batch[1].Error.Code != rpcInvalidRequest {
+		t.Errorf("1 in batch: got %+v", batch[1])
+	}
+	if string(batch[2].ID) != `"close"` || !batch[2].Result.Closed {
+		t.Errorf("close in batch: got %+v", batch[2])
+	}
+
+	if resp := decodeResponse(t, lines[1]); resp.Error == nil || resp.Error.Code != rpcParseError {
+		t.Errorf("unfinished batch: got %s", lines[1])
+	}
+}
+
+func TestRPCOffsets(t *testing.T) {
+	var tests = []struct {
+		name    string
+		content string
+		line    int
+	}{
+		//	a word before the discrepancy that takes one byte in the file but two in UTF-8
+		{"windows-1252", "Sing, O godd\xE9ss,\nthe angr of Achilles son of Peleus, that brought countless ils upon the Achaeans.\n", 2},
+		{"crlf", "Sing, O goddess,\r\n\r\nthe angr of Achilles son of Peleus, that brought countless ils upon the Achaeans.\r\n", 3},
+		{"bom", "\xEF\xBB\xBFSing, O goddess, the angr of Achilles son of Peleus, that brought countless ils upon the Achaeans.\n", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := newTestJob(t)
+			source := strings.Replace(strings.Replace(tt.content, "angr", "anger", 1), "ils", "ills", 1)
+			if err := job.SaveSession(tt.content, source, 0, 0, editingjob.HistorySaved); err != nil {
+				t.Fatalf("couldn't save edition: %v", err)
+			}
+
+			lines := rpcSession(t, `{"jsonrpc": "2.0", "id": 1, "method": "open", "params": {"job": "`+job.Name()+`"}}`)
+			view := decodeResponse(t, lines[0]).Result
+			if view.Edit == nil {
+				t.Fatalf("got %s", lines[0])
+			}
+			if got := tt.content[view.Edit.Offset:view.Edit.End]; got != "angr" {
+				t.Errorf("offsets %d-%d are %q in the file, want angr", view.Edit.Offset, view.Edit.End, got)
+			}
+			if view.Edit.Line != tt.line {
+				t.Errorf("got line %d, want %d", view.Edit.Line, tt.line)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package poweredit
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+//go:embed web/index.html
+var indexPage []byte
+
+// server serves the browser UI
+type server struct {
+	*openJobs
+}
+
+// serve - resolve discrepancies in a browser, on a local HTTP server
+func runServeCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: poweredit [--addr host:port] serve")
+	}
+
+	srv := &server{newOpenJobs(os.Stdout)}
+
+	//	save what's been resolved and let go of the jobs when the server is stopped
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGHUP, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		fmt.Printf("\n%s, closing jobs\n", sig)
+		srv.closeAll()
+		os.Exit(0)
+	}()
+
+	fmt.Printf("PowerEdit is at http://%s/, ctrl-c to stop\n", addrFlag)
+	return http.ListenAndServe(addrFlag, srv.handler(addrFlag))
+}
+
+// handler serves the page and the API to requests made to addr, localhost or 127.0.0.1. Any other
+// Host is refused, so a page elsewhere can't reach the API by pointing its own name at this machine
+func (srv *server) handler(addr string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(indexPage)
+	})
+	mux.HandleFunc("/api/jobs", srv.handleJobs)
+	mux.HandleFunc("/api/jobs/", srv.handleJob)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowedHost(r.Host, addr) {
+			httpError(w, fmt.Errorf("host %s not allowed", r.Host), http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost tells whether host, the Host of a request, is addr, localhost or 127.0.0.1
+func allowedHost(host, addr string) bool {
+	if strings.EqualFold(host, addr) {
+		return true
+	}
+	name, _, err := net.SplitHostPort(host)
+	if err != nil {
+		name = host
+	}
+	return strings.EqualFold(name, "localhost") || name == "127.0.0.1"
+}
+
+// GET /api/jobs lists the jobs
+func (srv *server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := srv.list()
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, jobs)
+}
+
+// /api/jobs/<job>/<action>: GET to see the discrepancy, POST open, resolve, save or close
+func (srv *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/")
+
+	//	only a page of this server's can post JSON to it without a CORS preflight
+	if r.Method == http.MethodPost && r.Header.Get("Content-Type") != "application/json" {
+		httpError(w, fmt.Errorf("expected application/json"), http.StatusUnsupportedMediaType)
+		return
+	}
+	if (r.Method == http.MethodGet) != (action == "") {
+		httpError(w, fmt.Errorf("%s %s not allowed", r.Method, r.URL.Path), http.StatusMethodNotAllowed)
+		return
+	}
+	if action == "" {
+		action = "discrepancy"
+	}
+	if action == "jobs" || !slices.Contains(jobMethods, action) {
+		http.NotFound(w, r)
+		return
+	}
+
+	params := jobParams{}
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil && err != io.EOF {
+			httpError(w, err, http.StatusBadRequest)
+			return
+		}
+	}
+	params.Job = name
+
+	view, err := srv.do(action, params)
+	if err != nil {
+		httpError(w, err, http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, view)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func httpError(w http.ResponseWriter, err error, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,165 @@
+package poweredit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// request makes a request of h as a page of the server would, decoding the JSON response into v
+func request(t *testing.T, h http.Handler, method, url, body string, v any) int {
+	t.Helper()
+
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, url, r)
+	req.Host = "localhost:7070"
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: couldn't decode %q: %v", method, url, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+func TestServe(t *testing.T) {
+	job := newTestJob(t)
+	h := (&server{newOpenJobs(io.Discard)}).handler("localhost:7070")
+	url := "/api/jobs/" + job.Name()
+
+	jobs := []jobView{}
+	if code := request(t, h, "GET", "/api/jobs", "", &jobs); code != http.StatusOK || len(jobs) != 1 || jobs[0].Name != job.Name() || jobs[0].Open {
+		t.Fatalf("got %d %+v", code, jobs)
+	}
+
+	view := sessionView{}
+	if code := request(t, h, "POST", url+"/open", "", &view); code != http.StatusOK || view.Edit == nil || view.Edit.Word != "angr" || view.Source.Word != "anger" {
+		t.Fatalf("open: got %d %+v", code, view)
+	}
+
+	if request(t, h, "GET", "/api/jobs", "", &jobs); !jobs[0].Open {
+		t.Errorf("open job not listed as open: %+v", jobs)
+	}
+
+	view = sessionView{}
+	if code := request(t, h, "GET", url, "", &view); code != http.StatusOK || view.Edit.Word != "angr" {
+		t.Errorf("discrepancy: got %d %+v", code, view)
+	}
+
+	view = sessionView{}
+	if code := request(t, h, "POST", url+"/resolve", `{"command": "e"}`, &view); code != http.StatusOK || view.Edit.Word != "ils" || view.Unsaved != 1 {
+		t.Errorf("resolve: got %d %+v", code, view)
+	}
+
+	view = sessionView{}
+	if code := request(t, h, "POST", url+"/save", "", &view); code != http.StatusOK || view.Message != "saved" || view.Unsaved != 0 {
+		t.Errorf("save: got %d %+v", code, view)
+	}
+
+	view = sessionView{}
+	if code := request(t, h, "POST", url+"/resolve", `{"command": "e"}`, &view); code != http.StatusOK || !view.Done {
+		t.Errorf("resolve last discrepancy: got %d %+v", code, view)
+	}
+
+	failed := map[string]string{}
+	if code := request(t, h, "POST", url+"/resolve", `{"command": "d"}`, &failed); code != http.StatusBadRequest || failed["error"] != "no discrepancies left" {
+		t.Errorf("resolve with no discrepancies left: got %d %v", code, failed)
+	}
+
+	view = sessionView{}
+	if code := request(t, h, "POST", url+"/resolve", `{"command": "v"}`, &view); code != http.StatusOK || !view.Closed {
+		t.Errorf("v with no discrepancies left: got %d %+v", code, view)
+	}
+
+	if request(t, h, "GET", "/api/jobs", "", &jobs); jobs[0].Open || jobs[0].Saves != 2 {
+		t.Errorf("got %+v, want a closed job saved twice", jobs)
+	}
+
+	view = sessionView{}
+	request(t, h, "POST", url+"/open", "", &view)
+	if code := request(t, h, "POST", url+"/close", "", &view); code != http.StatusOK || !view.Closed {
+		t.Errorf("close: got %d %+v", code, view)
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	job := newTestJob(t)
+	h := (&server{newOpenJobs(io.Discard)}).handler("localhost:7070")
+	url := "/api/jobs/" + job.Name()
+
+	var tests = []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		code   int
+	}{
+		{"not open", "GET", url, "", http.StatusBadRequest},
+		{"no such job", "POST", "/api/jobs/nojob/open", "", http.StatusBadRequest},
+		{"unknown action", "POST", url + "/rename", "", http.StatusNotFound},
+		{"jobs isn't an action", "POST", url + "/jobs", "", http.StatusNotFound},
+		{"GET an action", "GET", url + "/open", "", http.StatusMethodNotAllowed},
+		{"bad JSON", "POST", url + "/open", "{", http.StatusBadRequest},
+		{"not a page", "GET", "/index.html", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := request(t, h, tt.method, tt.url, tt.body, nil); code != tt.code {
+				t.Errorf("got %d, want %d", code, tt.code)
+			}
+		})
+	}
+
+	t.Run("not JSON", func(t *testing.T) {
+		req := httptest.NewRequest("POST", url+"/open", strings.NewReader("command=e"))
+		req.Host = "localhost:7070"
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("got %d, want %d", w.Code, http.StatusUnsupportedMediaType)
+		}
+	})
+}
+
+func TestServeHost(t *testing.T) {
+	newTestJob(t)
+	h := (&server{newOpenJobs(io.Discard)}).handler("192.168.1.5:7070")
+
+	var tests = []struct {
+		host string
+		code int
+	}{
+		{"localhost:7070", http.StatusOK},
+		{"127.0.0.1:7070", http.StatusOK},
+		{"LOCALHOST", http.StatusOK},
+		{"192.168.1.5:7070", http.StatusOK},
+		{"192.168.1.5:8000", http.StatusForbidden},
+		{"attacker.example:7070", http.StatusForbidden},
+		{"localhost.attacker.example", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/jobs", nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Errorf("got %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+}
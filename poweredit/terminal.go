@@ -0,0 +1,148 @@
+package poweredit
+
+import (
+	"fmt"
+	"os"
+	"poweredit/editingjob"
+	"poweredit/session"
+	"poweredit/utils"
+	"strings"
+)
+
+// terminal resolves each discrepancy by showing it and asking for a command, saving or
+// confirming before throwing work away on ctrl-c or hangup
+type terminal struct {
+	onInterrupt string // 'save', or 'ask' on ctrl-c
+}
+
+func (t *terminal) Resolve(s *session.Session, d session.Discrepancy) (session.Decision, error) {
+	printDisplay(s, d)
+	if s.ReadOnly {
+		fmt.Printf("\tREAD-ONLY: job is open in another session, changes can't be saved\n\n")
+	}
+
+	for {
+		if in.script {
+			fmt.Print("\tenter selection: ")
+		} else {
+			printResolutionOptions()
+		}
+		choice, ok := t.read(s, "\tenter selection: ")
+		if !ok {
+			return scriptEnded(s), nil
+		}
+
+		decision, err := session.Command(choice)
+		if err != nil {
+			fmt.Printf("Not a valid command: %s\n", choice)
+			continue
+		}
+
+		//	manually enter word and edit both by this word
+		for decision.Action == session.Custom {
+			fmt.Print("enter word to edit both by: ")
+			word, ok := t.read(s, "enter word to edit both by: ")
+			if !ok {
+				return scriptEnded(s), nil
+			}
+			fmt.Printf("save '%s' to both indexes? (y/n): ", word)
+			answer, ok := t.read(s, "(y/n): ")
+			if !ok {
+				return scriptEnded(s), nil
+			}
+			if strings.ToLower(answer) == "y" {
+				decision.Word = word
+				break
+			}
+			printDisplay(s, d)
+		}
+
+		return decision, nil
+	}
+}
+
+// read reads the next command, dealing with any interruption while waiting for it. It
+// returns false when a script, or commands piped in, run out
+func (t *terminal) read(s *session.Session, prompt string) (string, bool) {
+	for {
+		token, sig := in.next()
+		if sig == nil {
+			return token, true
+		}
+		if _, ok := sig.(hangup); ok && in.script {
+			return "", false
+		}
+		t.interrupt(s, sig)
+		fmt.Print(prompt)
+	}
+}
+
+// scriptEnded quits without saving when a script runs out of commands, as it can't be told
+// apart from one cut short; a script ends with v to save
+func scriptEnded(s *session.Session) session.Decision {
+	fmt.Printf("\n\nend of script, quitting without saving %d unsaved resolutions\n", s.Unsaved())
+	return session.Decision{Action: session.Quit}
+}
+
+// interrupt saves the session and exits, or asks first on ctrl-c unless the job's on-interrupt
+// setting is 'save'. It returns only if the session is to carry on. The end of a script
+// doesn't get here, see scriptEnded
+func (t *terminal) interrupt(s *session.Session, sig os.Signal) {
+	if s.ReadOnly || s.Unsaved() == 0 {
+		fmt.Printf("\n\n%s, nothing to save\n", sig)
+		exit(0)
+	}
+
+	//	a hangup has no terminal left to ask, so always saves
+	if sig == os.Interrupt && t.onInterrupt != "save" {
+		switch t.ask(s) {
+		case "q":
+			clearScreen()
+			exit(0)
+		case "c":
+			printDisplay(s, s.Current())
+			return
+		}
+	}
+
+	unsaved := s.Unsaved()
+	if err := s.Save(editingjob.HistorySaved); err != nil {
+		fmt.Printf("\n\n%s, but saving failed, the job is still at its previous edition: %v\n", sig, err)
+		exit(1)
+	}
+
+	fmt.Printf("\n\n%s, %d unsaved resolutions have been saved\n\nleft at indexes [i = %d] [j = %d]\n\n", sig, unsaved, s.I, s.J)
+	exit(0)
+}
+
+// ask asks what to do on ctrl-c until the answer is s, q or c. Ctrl-c again answers q, and
+// the end of input s, as there's no one left to ask
+func (t *terminal) ask(s *session.Session) string {
+	fmt.Printf("\n\n\tinterrupted with %d unsaved resolutions\n"+
+		"\ts - save and quit\n\tq - quit without saving (or ctrl-c again)\n\tc - continue editing\n\n\tenter selection: ", s.Unsaved())
+
+	for {
+		answer, sig := in.next()
+		switch {
+		case sig == os.Interrupt:
+			return "q"
+		case sig != nil:
+			return "s"
+		case answer == "s" || answer == "q" || answer == "c":
+			return answer
+		}
+		fmt.Printf("Not a valid selection: %s\n\tenter selection: ", answer)
+	}
+}
+
+// clearScreen clears the terminal, unless commands are read from a script
+func clearScreen() {
+	if !in.script {
+		utils.ClearScreen()
+	}
+}
+
+func display(dsp string) {
+	clearScreen()
+	fmt.Println(dsp)
+}
@@ -0,0 +1,139 @@
+package poweredit
+
+import (
+	"io"
+	"os"
+	"path"
+	"poweredit/editingjob"
+	"poweredit/session"
+	"strings"
+	"testing"
+)
+
+// newTestJob creates a job in a store of its own, whose files have two discrepancies
+func newTestJob(t *testing.T) *editingjob.EditingJob {
+	t.Helper()
+
+	store = &editingjob.Store{JobDirectory: t.TempDir()}
+	dir := t.TempDir()
+	editFile := path.Join(dir, "iliad.txt")
+	sourceFile := path.Join(dir, "scan.txt")
+	os.WriteFile(editFile, []byte("Sing, O goddess, the angr of Achilles son of Peleus, that brought countless ils upon the Achaeans.\n"), 0644)
+	os.WriteFile(sourceFile, []byte("Sing, O goddess, the anger of Achilles son of Peleus, that brought countless ills upon the Achaeans.\n"), 0644)
+
+	job, err := store.FromEditAndSourceFiles(editFile, sourceFile)
+	if err != nil {
+		t.Fatalf("couldn't create job: %v", err)
+	}
+	return job
+}
+
+func TestScript(t *testing.T) {
+	var tests = []struct {
+		name    string
+		script  string
+		outcome session.Outcome
+		saved   bool
+	}{
+		{"save", "e # anger\ne\nv\n", session.Saved, true},
+		{"all resolved", "e e", session.Saved, true},
+		{"quit", "e q", session.Quitted, false},
+		{"ends before the last discrepancy", "e\n", session.Quitted, false},
+		{"ends asking for a word", "e me", session.Quitted, false},
+		{"ends asking to confirm a word", "e me ills", session.Quitted, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := newTestJob(t)
+			editions := len(job.History())
+
+			s, err := session.New(job, -1, -1)
+			if err != nil {
+				t.Fatalf("couldn't start session: %v", err)
+			}
+			in = newInput(strings.NewReader(tt.script), true)
+
+			outcome, err := s.Run(&terminal{onInterrupt: "save"})
+			if err != nil {
+				t.Fatalf("session resulted in error: %v", err)
+			}
+			if outcome != tt.outcome {
+				t.Errorf("got outcome %d, want %d", outcome, tt.outcome)
+			}
+			if saved := len(job.History()) > editions; saved != tt.saved {
+				t.Errorf("got saved %v, want %v", saved, tt.saved)
+			}
+		})
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	var tests = []struct {
+		name    string
+		answers string
+		again   bool // ctrl-c again at the prompt
+		want    string
+	}{
+		{"save", "s\n", false, "s"},
+		{"quit", "q\n", false, "q"},
+		{"continue", "c\n", false, "c"},
+		{"typo then continue", "x\n\nsq\nc\n", false, "c"},
+		{"typo then quit", "Q\nq\n", false, "q"},
+		{"ctrl-c again", "", true, "q"},
+		{"end of input", "", false, "s"},
+		{"typo then end of input", "w\n", false, "s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := session.New(newTestJob(t), -1, -1)
+			r, w := io.Pipe()
+			in = newInput(r, false)
+
+			//	nothing is written until the interrupt is taken, so it can't race the answers
+			in.signals <- os.Interrupt
+			if _, sig := in.next(); sig != os.Interrupt {
+				t.Fatalf("got signal %v", sig)
+			}
+
+			if tt.again {
+				in.signals <- os.Interrupt
+			} else {
+				go func() {
+					io.WriteString(w, tt.answers)
+					w.Close()
+				}()
+			}
+
+			if got := (&terminal{onInterrupt: "ask"}).ask(s); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("continue editing", func(t *testing.T) {
+		s, _ := session.New(newTestJob(t), -1, -1)
+		s.Next()
+		s.Apply(session.Decision{Action: session.ReplaceSource})
+
+		r, w := io.Pipe()
+		in = newInput(r, true)
+		in.signals <- os.Interrupt
+		_, sig := in.next()
+
+		go func() {
+			io.WriteString(w, "x c e\n")
+			w.Close()
+		}()
+
+		//	returns rather than exiting, leaving the next command to be read
+		(&terminal{onInterrupt: "ask"}).interrupt(s, sig)
+		if token, sig := in.next(); sig != nil || token != "e" {
+			t.Errorf("got %q, %v after continuing", token, sig)
+		}
+		if s.Unsaved() != 1 {
+			t.Errorf("continuing changed the session, %d unsaved resolutions", s.Unsaved())
+		}
+	})
+}
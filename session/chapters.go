@@ -0,0 +1,77 @@
+package session
+
+import "poweredit/textwords"
+
+// pairChapters pairs the chapter headings within the bodies of the two files, after an anchor
+// at the start of the bodies so every word is in a chapter. It is done again after a resolution
+// near a heading, as headings can be added, deleted or corrected
+func (s *Session) pairChapters() {
+	editEnd := s.Edit.Len() - s.editFooter
+	sourceEnd := s.Source.Len() - s.sourceFooter
+
+	as := []textwords.Anchor{{Edit: s.editStart, Source: s.sourceStart}}
+	for _, a := range textwords.PairChapters(s.Edit, s.Source) {
+		if a.Edit >= s.editStart && a.Edit < editEnd && a.Source >= s.sourceStart && a.Source < sourceEnd {
+			if a.Edit == s.editStart && a.Source == s.sourceStart {
+				as = as[:0]
+			}
+			as = append(as, a)
+		}
+	}
+	s.anchors = as
+}
+
+// nearHeading tells if the words a decision changes are on or next to a chapter heading line
+func (s *Session) nearHeading(a Action) bool {
+	switch a {
+	case Add, Replace, Delete:
+		return s.Edit.NearHeading(s.I)
+	case ReplaceSource, DeleteSource:
+		return s.Source.NearHeading(s.J)
+	case Custom:
+		return s.Edit.NearHeading(s.I) || s.Source.NearHeading(s.J)
+	}
+	return false
+}
+
+// shiftAnchors moves the chapters starting after word at of the file under edit, or of the
+// source file, by delta words, for a word added or deleted at at
+func (s *Session) shiftAnchors(edit bool, at, delta int) {
+	for k := range s.anchors {
+		if edit && s.anchors[k].Edit > at {
+			s.anchors[k].Edit += delta
+		} else if !edit && s.anchors[k].Source > at {
+			s.anchors[k].Source += delta
+		}
+	}
+}
+
+// Anchors are the chapters paired in both files, the first being the start of their bodies
+func (s *Session) Anchors() []textwords.Anchor {
+	return s.anchors
+}
+
+// ChapterOf is the index of the anchor starting the chapter a word of the file under edit, or
+// of the source file, is in
+func (s *Session) ChapterOf(at int, edit bool) int {
+	n := 0
+	for k, a := range s.anchors {
+		start := a.Source
+		if edit {
+			start = a.Edit
+		}
+		if start > at {
+			break
+		}
+		n = k
+	}
+	return n
+}
+
+// ChapterTitle is the title of the chapter starting at anchor k
+func (s *Session) ChapterTitle(k int) string {
+	if s.anchors[k].Title == "" {
+		return "the text before the first chapter"
+	}
+	return s.anchors[k].Title
+}
@@ -0,0 +1,43 @@
+package session
+
+import (
+	"fmt"
+	"poweredit/utils"
+)
+
+// Command reads a decision from one of PowerEdit's resolution commands: a, e, ex, me, d, x, n,
+// p, v, q, or one or two digits to advance the cursors by. The word for me is left for the
+// caller to ask for
+func Command(choice string) (Decision, error) {
+	switch choice {
+	case "a":
+		return Decision{Action: Add}, nil
+	case "e":
+		return Decision{Action: Replace}, nil
+	case "ex":
+		return Decision{Action: ReplaceSource}, nil
+	case "me":
+		return Decision{Action: Custom}, nil
+	case "d":
+		return Decision{Action: Delete}, nil
+	case "x":
+		return Decision{Action: DeleteSource}, nil
+	case "n":
+		return Decision{Action: NextChapter}, nil
+	case "p":
+		return Decision{Action: PreviousChapter}, nil
+	case "v":
+		return Decision{Action: Save}, nil
+	case "q":
+		return Decision{Action: Quit}, nil
+	}
+
+	digits, err := utils.ParseDigits(choice)
+	if err != nil || len(digits) == 0 {
+		return Decision{}, fmt.Errorf("not a valid command: %s", choice)
+	}
+	if len(digits) == 1 {
+		return Decision{Action: Advance, EditBy: digits[0]}, nil
+	}
+	return Decision{Action: Advance, EditBy: digits[0], SourceBy: digits[1]}, nil
+}
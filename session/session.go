@@ -0,0 +1,321 @@
+// Package session compares the two files of an editing job word by word, stopping at each
+// discrepancy for a Resolver to decide what to do about it. It holds everything a session of
+// PowerEdit works on, without a terminal, so other frontends, tools and tests can drive it
+package session
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"poweredit/editingjob"
+	"poweredit/textwords"
+	"poweredit/utils"
+	"strconv"
+)
+
+// Session is a job being edited: the words of both of its files, and the cursors into them.
+// I and J are the indexes of the words being compared in the file under edit and the source
+// file
+type Session struct {
+	Job    *editingjob.EditingJob
+	Edit   *textwords.TextWords
+	Source *textwords.TextWords
+	I, J   int
+
+	ReadOnly bool      // changes can't be saved
+	Autosave int       // save the session every n resolutions, 0 for never
+	Rewrap   int       // on save, rewrap changed paragraphs of the file under edit to n columns, 0 for never
+	Log      io.Writer // where autosave failures and fallbacks to UTF-8 are reported
+
+	//	the header and footer of the files, left out of the comparison. The footers are counted
+	//	from the end, so they stay put as words are added and deleted before them
+	editStart, editFooter     int
+	sourceStart, sourceFooter int
+
+	anchors []textwords.Anchor // chapters paired as anchors, the first being the start of the bodies
+	count   *tally
+
+	found       bool // a discrepancy has been found
+	resolutions int
+	unsaved     int
+}
+
+// Discrepancy is a pair of words that don't match, at indexes Edit and Source. The chapters are
+// indexes into Anchors, and differ when one file reaches the end of a chapter before the other
+type Discrepancy struct {
+	Edit, Source               int
+	EditWord, SourceWord       textwords.WordLoc
+	EditChapter, SourceChapter int
+}
+
+// Action is what to do about a discrepancy
+type Action int
+
+const (
+	Advance         Action = iota // move the cursors on by EditBy and SourceBy words, leaving the discrepancy
+	Add                           // add the source word to the file under edit
+	Replace                       // set the word of the file under edit to the source word
+	ReplaceSource                 // set the source word to the word of the file under edit
+	Custom                        // set both words to Word
+	Delete                        // delete the word from the file under edit
+	DeleteSource                  // delete the word from the source file
+	NextChapter                   // go on to the start of the next chapter in both files
+	PreviousChapter               // go back to the start of the previous chapter in both files
+	Save                          // save changes and end the session
+	Quit                          // end the session without saving
+)
+
+// Decision is a Resolver's answer to a discrepancy
+type Decision struct {
+	Action           Action
+	EditBy, SourceBy int    // for Advance
+	Word             string // for Custom
+}
+
+// Resolver decides what to do about each discrepancy of a session. An error ends the session
+// without saving
+type Resolver interface {
+	Resolve(s *Session, d Discrepancy) (Decision, error)
+}
+
+// Outcome is how a session ended
+type Outcome int
+
+const (
+	Identical Outcome = iota // there were no discrepancies
+	Saved                    // the session was saved as a new edition
+	NotSaved                 // the session was read-only
+	Quitted                  // the resolver quit without saving
+)
+
+// ErrNoChapter is returned by Apply when there is no chapter to go to
+var ErrNoChapter = errors.New("no chapter to go to")
+
+// New starts a session of a job, reading both of its files as the job's settings say. i and j
+// are where to start comparing, or below 0 to pick up where the job left off
+func New(job *editingjob.EditingJob, i, j int) (*Session, error) {
+	if i < 0 {
+		i = job.LastEditingIndex
+	}
+	if j < 0 {
+		j = job.LastSourceIndex
+	}
+
+	editWords, err := job.Words(job.LatestEditFile())
+	if err != nil {
+		return nil, fmt.Errorf("error getting edit words: %v", err)
+	}
+
+	sourceWords, err := job.Words(job.LatestSrceFile())
+	if err != nil {
+		return nil, fmt.Errorf("error getting source words: %v", err)
+	}
+
+	s := &Session{Job: job, Edit: editWords, Source: sourceWords, Log: io.Discard}
+	s.Autosave, _ = strconv.Atoi(job.Setting("autosave"))
+	s.Rewrap, _ = strconv.Atoi(job.Setting("rewrap"))
+
+	editStart, editEnd := job.Body(editWords)
+	sourceStart, sourceEnd := job.Body(sourceWords)
+	s.editStart, s.editFooter = editStart, editWords.Len()-editEnd
+	s.sourceStart, s.sourceFooter = sourceStart, sourceWords.Len()-sourceEnd
+
+	s.I = max(i, editStart)
+	s.J = max(j, sourceStart)
+
+	s.pairChapters()
+
+	//	count the discrepancies in the background, so the session can start straight away
+	s.count = countDiscrepancies(job.Discrepancies(editWords, sourceWords), editWords.Len())
+
+	return s, nil
+}
+
+// Next moves the cursors on past matching words to the next discrepancy, returning false when
+// the end of either file's body is reached
+func (s *Session) Next() (Discrepancy, bool) {
+	for s.I < s.Edit.Len()-s.editFooter && s.J < s.Source.Len()-s.sourceFooter {
+		editWord := utils.ReplaceQuotes(s.Edit.GetWord(s.I).W)
+		sourceWord := utils.ReplaceQuotes(s.Source.GetWord(s.J).W)
+
+		if editWord == "" {
+			s.I++
+			continue
+		}
+		if sourceWord == "" {
+			s.J++
+			continue
+		}
+
+		//	words in different chapters never match, however alike
+		if editWord != sourceWord || s.ChapterOf(s.I, true) != s.ChapterOf(s.J, false) {
+			s.found = true
+			return s.Current(), true
+		}
+
+		s.I++
+		s.J++
+	}
+
+	return Discrepancy{}, false
+}
+
+// Current is the discrepancy at the cursors
+func (s *Session) Current() Discrepancy {
+	return Discrepancy{
+		Edit:          s.I,
+		Source:        s.J,
+		EditWord:      s.Edit.GetWord(s.I),
+		SourceWord:    s.Source.GetWord(s.J),
+		EditChapter:   s.ChapterOf(s.I, true),
+		SourceChapter: s.ChapterOf(s.J, false),
+	}
+}
+
+// Apply carries out a decision about the discrepancy at the cursors, autosaving if it is time
+// to. Save and Quit only end Run, they don't save or throw anything away themselves
+func (s *Session) Apply(d Decision) error {
+	editWord := s.Edit.GetWord(s.I)
+	sourceWord := s.Source.GetWord(s.J)
+
+	//	the chapters only need finding again if a heading line was, or has become, part of the
+	//	change; otherwise the chapters after the change just move with the words
+	touched := s.nearHeading(d.Action)
+
+	switch d.Action {
+	case Advance:
+		s.I += d.EditBy
+		s.J += d.SourceBy
+	case Add:
+		s.Edit.Insert(sourceWord, s.I)
+		s.shiftAnchors(true, s.I, 1)
+	case Replace:
+		s.Edit.Edit(s.I, sourceWord)
+	case ReplaceSource:
+		s.Source.Edit(s.J, editWord)
+	case Custom:
+		editWord.W = d.Word
+		sourceWord.W = d.Word
+		s.Edit.Edit(s.I, editWord)
+		s.Source.Edit(s.J, sourceWord)
+	case Delete:
+		s.Edit.Delete(s.I)
+		s.shiftAnchors(true, s.I, -1)
+	case DeleteSource:
+		s.Source.Delete(s.J)
+		s.shiftAnchors(false, s.J, -1)
+	case NextChapter, PreviousChapter:
+		at := max(s.ChapterOf(s.I, true), s.ChapterOf(s.J, false)) + 1
+		if d.Action == PreviousChapter {
+			at = min(s.ChapterOf(s.I, true), s.ChapterOf(s.J, false)) - 1
+		}
+		if at < 0 || at >= len(s.anchors) {
+			return ErrNoChapter
+		}
+		s.I, s.J = s.anchors[at].Edit, s.anchors[at].Source
+	case Save, Quit:
+		return nil
+	default:
+		return fmt.Errorf("unknown action %d", d.Action)
+	}
+
+	if touched || s.nearHeading(d.Action) {
+		s.pairChapters()
+	}
+
+	s.resolutions++
+	s.unsaved++
+	if s.Autosave > 0 && s.resolutions%s.Autosave == 0 && !s.ReadOnly {
+		if err := s.Save(editingjob.HistoryAutosave); err != nil {
+			fmt.Fprintf(s.Log, "Autosave failed: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+// Run resolves each discrepancy in turn until the end of the files, or the resolver saves or
+// quits. Changes are saved as a new edition unless the session is read-only, the resolver
+// quit, or there is nothing left unsaved. Decisions that can't be applied are reported to Log and the discrepancy asked about again
+func (s *Session) Run(r Resolver) (Outcome, error) {
+	for {
+		d, ok := s.Next()
+		if !ok {
+			break
+		}
+
+		decision, err := r.Resolve(s, d)
+		if err != nil {
+			return NotSaved, err
+		}
+		if decision.Action == Quit {
+			return Quitted, nil
+		}
+		if err := s.Apply(decision); err != nil {
+			fmt.Fprintln(s.Log, err)
+			continue
+		}
+		if decision.Action == Save {
+			break
+		}
+	}
+
+	switch {
+	case !s.found:
+		return Identical, nil
+	case s.ReadOnly:
+		return NotSaved, nil
+	case s.unsaved == 0:
+		return Saved, nil //	nothing resolved since the last autosave, another edition would repeat it
+	}
+
+	if err := s.Save(editingjob.HistorySaved); err != nil {
+		return NotSaved, fmt.Errorf("error saving %s, the job is still at its previous edition: %v", s.Job.Name(), err)
+	}
+	return Saved, nil
+}
+
+// Save saves both files as a new edition, first rewrapping changed paragraphs of the body if
+// asked to, and
+// records the cursors as where the job left off. kind is editingjob.HistorySaved, or
+// editingjob.HistoryAutosave for a save part way through a session
+func (s *Session) Save(kind string) error {
+	if s.ReadOnly {
+		return fmt.Errorf("%s is open read-only", s.Job.Name())
+	}
+	if s.Rewrap > 0 {
+		s.Edit.Rewrap(s.Rewrap, s.editStart, s.Edit.Len()-s.editFooter)
+	}
+	if err := s.Job.SaveSession(s.content(s.Edit), s.content(s.Source), s.I, s.J, kind); err != nil {
+		return err
+	}
+	s.unsaved = 0
+	return nil
+}
+
+// Unsaved is the number of resolutions since the session was last saved
+func (s *Session) Unsaved() int {
+	return s.unsaved
+}
+
+// Left is an estimate of how many discrepancies are left from the cursor on, and whether they
+// have all been counted yet. The count comes from aligning the files in the background, while
+// Next walks them word by word in step, so a run of added or deleted words counts once but can
+// take several resolutions, and the two can disagree about what is a discrepancy
+func (s *Session) Left() (int, bool) {
+	return s.count.left(s.I, s.Edit.Len())
+}
+
+// content is the text to save as an edition, in the same encoding and line endings as the file
+// it was read from. Text that can no longer be written in that encoding is saved as UTF-8, rather
+// than losing the session's work
+func (s *Session) content(tw *textwords.TextWords) string {
+	b, err := tw.Bytes()
+	if err != nil {
+		fmt.Fprintf(s.Log, "Saving as UTF-8 instead of %s: %v\n", tw.Format().Encoding, err)
+		format := tw.Format()
+		format.Encoding = textwords.UTF8
+		b, _ = textwords.Encode(tw.Text(), format)
+	}
+	return string(b)
+}
@@ -0,0 +1,277 @@
+package session
+
+import (
+	"os"
+	"path"
+	"poweredit/editingjob"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// script resolves discrepancies with a list of commands, recording the words of each one
+type script struct {
+	commands []string
+	seen     []string
+}
+
+func (sc *script) Resolve(s *Session, d Discrepancy) (Decision, error) {
+	sc.seen = append(sc.seen, d.EditWord.W+"/"+d.SourceWord.W)
+	decision, err := Command(sc.commands[0])
+	sc.commands = sc.commands[1:]
+	return decision, err
+}
+
+func newJob(t *testing.T, edit, source string) *editingjob.EditingJob {
+	store := &editingjob.Store{JobDirectory: t.TempDir()}
+	dir := t.TempDir()
+	editFile := path.Join(dir, "iliad.txt")
+	sourceFile := path.Join(dir, "scan.txt")
+	os.WriteFile(editFile, []byte(edit), 0644)
+	os.WriteFile(sourceFile, []byte(source), 0644)
+
+	job, err := store.FromEditAndSourceFiles(editFile, sourceFile)
+	if err != nil {
+		t.Fatalf("couldn't create job: %v", err)
+	}
+	return job
+}
+
+func TestRun(t *testing.T) {
+	edit := "BOOK I.\n\nSing, goddess, the wrath of Achillcs Peleus' son, the ruinous wrath that brought woes\n"
+	source := "BOOK I.\n\nSing, goddess, the wrath of Achilles Peleus' son, the ruinous wrath that brought on woes\n"
+
+	t.Run("resolve and save", func(t *testing.T) {
+		job := newJob(t, edit, source)
+		s, err := New(job, -1, -1)
+		if err != nil {
+			t.Fatalf("couldn't start session: %v", err)
+		}
+
+		sc := &script{commands: []string{"e", "a"}}
+		outcome, err := s.Run(sc)
+		if err != nil || outcome != Saved {
+			t.Fatalf("got outcome %d, %v", outcome, err)
+		}
+		if len(sc.seen) != 2 || sc.seen[0] != "Achillcs/Achilles" || sc.seen[1] != "woes/on" {
+			t.Errorf("got discrepancies %v", sc.seen)
+		}
+
+		content, _ := os.ReadFile(job.LatestEditFile())
+		if string(content) != source {
+			t.Errorf("\ngot:  %q\nwant: %q", content, source)
+		}
+		if job.LastEditingIndex != s.I || job.LastSourceIndex != s.J {
+			t.Errorf("job left at %d %d, session at %d %d", job.LastEditingIndex, job.LastSourceIndex, s.I, s.J)
+		}
+	})
+
+	t.Run("autosave", func(t *testing.T) {
+		job := newJob(t, edit, source)
+		s, _ := New(job, -1, -1)
+		s.Autosave = 1
+
+		outcome, err := s.Run(&script{commands: []string{"e", "a"}})
+		if err != nil || outcome != Saved {
+			t.Fatalf("got outcome %d, %v", outcome, err)
+		}
+
+		//	the second autosave replaces the first, and leaves nothing for the end of the session to save
+		history := job.History()
+		if len(history) != 2 || history[1].Kind != editingjob.HistoryAutosave || history[1].Edition != 2 {
+			t.Errorf("got history %v", history)
+		}
+		if editions, _ := job.Editions(); len(editions) != 2 {
+			t.Errorf("got editions %v", editions)
+		}
+		content, _ := os.ReadFile(job.LatestEditFile())
+		if string(content) != source {
+			t.Errorf("\ngot:  %q\nwant: %q", content, source)
+		}
+	})
+
+	t.Run("quit", func(t *testing.T) {
+		job := newJob(t, edit, source)
+		s, _ := New(job, -1, -1)
+
+		outcome, err := s.Run(&script{commands: []string{"e", "q"}})
+		if err != nil || outcome != Quitted {
+			t.Fatalf("got outcome %d, %v", outcome, err)
+		}
+		if len(job.History()) != 1 || s.Unsaved() != 1 {
+			t.Errorf("quitting saved the session: %v", job.History())
+		}
+	})
+
+	t.Run("identical", func(t *testing.T) {
+		s, _ := New(newJob(t, source, source), -1, -1)
+		if outcome, err := s.Run(&script{}); err != nil || outcome != Identical {
+			t.Errorf("got outcome %d, %v", outcome, err)
+		}
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		job := newJob(t, edit, source)
+		s, _ := New(job, -1, -1)
+		s.ReadOnly = true
+
+		outcome, err := s.Run(&script{commands: []string{"d", "x", "a"}})
+		if err != nil || outcome != NotSaved {
+			t.Fatalf("got outcome %d, %v", outcome, err)
+		}
+		if len(job.History()) != 1 {
+			t.Errorf("read-only session was saved: %v", job.History())
+		}
+	})
+}
+
+func TestApply(t *testing.T) {
+	s, _ := New(newJob(t, "BOOK I.\n\nSing, goddess, the wrath\n", "BOOK I.\n\nSing, O goddess, the wrath\n"), -1, -1)
+
+	d, ok := s.Next()
+	if !ok || d.EditWord.W != "goddess," || d.SourceWord.W != "O" || s.ChapterTitle(d.EditChapter) != "BOOK I." {
+		t.Fatalf("got discrepancy %+v", d)
+	}
+
+	if err := s.Apply(Decision{Action: NextChapter}); err != ErrNoChapter {
+		t.Errorf("got %v, want %v", err, ErrNoChapter)
+	}
+
+	if err := s.Apply(Decision{Action: Custom, Word: "Oh"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Edit.GetWord(d.Edit).W != "Oh" || s.Source.GetWord(d.Source).W != "Oh" {
+		t.Errorf("custom word not set in both files")
+	}
+
+	//	the edit's "goddess," was replaced, so the source's is left over
+	d, ok = s.Next()
+	if !ok || d.EditWord.W != "the" || d.SourceWord.W != "goddess," {
+		t.Fatalf("got discrepancy %+v", d)
+	}
+
+	if err := s.Apply(Decision{Action: Advance, SourceBy: 1}); err != nil || s.I != d.Edit || s.J != d.Source+1 {
+		t.Errorf("advanced to %d %d: %v", s.I, s.J, err)
+	}
+
+	if _, ok := s.Next(); ok {
+		t.Errorf("found a discrepancy after the last one")
+	}
+	if s.Unsaved() != 2 {
+		t.Errorf("got %d unsaved resolutions, want 2", s.Unsaved())
+	}
+}
+
+func TestAnchorsFollowEdits(t *testing.T) {
+	text := "BOOK I.\n\nSing, goddess, the wrath\n\nBOOK II.\n\nNow the other gods\nslept\n\nBOOK III.\n\nWhen the companies\n"
+	s, _ := New(newJob(t, text, text), -1, -1)
+
+	var steps = []struct {
+		name     string
+		i, j     int // the words of the file under edit and the source to resolve at
+		decision Decision
+		anchors  int // chapters paired after
+	}{
+		{"add in a chapter", 3, 2, Decision{Action: Add}, 3},
+		{"delete in a chapter", 3, 2, Decision{Action: Delete}, 3},
+		{"delete from source", 9, 9, Decision{Action: DeleteSource}, 3},
+		{"edit heading number", 7, 8, Decision{Action: Replace}, 2},
+		{"edit heading number back", 7, 7, Decision{Action: Replace}, 3},
+		{"delete heading word", 6, 6, Decision{Action: Delete}, 2},
+		{"add heading word back", 6, 6, Decision{Action: Add}, 3},
+		{"add on the line before a heading", 6, 5, Decision{Action: Add}, 3},
+	}
+
+	for _, step := range steps {
+		s.I, s.J = step.i, step.j
+		if err := s.Apply(step.decision); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		got := slices.Clone(s.Anchors())
+		s.pairChapters()
+		if !slices.Equal(got, s.Anchors()) {
+			t.Errorf("%s: got anchors %v, pairing again gives %v", step.name, got, s.Anchors())
+		}
+		if len(s.Anchors()) != step.anchors {
+			t.Errorf("%s: got %d anchors, want %d", step.name, len(s.Anchors()), step.anchors)
+		}
+	}
+}
+
+// BenchmarkApply resolves discrepancies in the Iliad, by adding and deleting words away from the
+// chapter headings
+func BenchmarkApply(b *testing.B) {
+	iliad, err := os.ReadFile("../textwords/test/gutenberg-iliad.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	store := &editingjob.Store{JobDirectory: b.TempDir()}
+	file := path.Join(b.TempDir(), "iliad.txt")
+	os.WriteFile(file, iliad, 0644)
+	job, err := store.FromEditAndSourceFiles(file, file)
+	if err != nil {
+		b.Fatal(err)
+	}
+	s, _ := New(job, -1, -1)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		s.I = s.editStart + (n*7919)%(s.Edit.Len()-s.editFooter-s.editStart-1)
+		action := Add
+		if n%2 == 1 {
+			action = Delete
+		}
+		s.Apply(Decision{Action: action})
+	}
+}
+
+func TestRewrapKeepsBoilerplate(t *testing.T) {
+	header := "*** START OF THE PROJECT GUTENBERG EBOOK THE ILIAD OF HOMER TRANSLATED BY SAMUEL BUTLER ***\n\n"
+	footer := "\n\n*** END OF THE PROJECT GUTENBERG EBOOK THE ILIAD OF HOMER TRANSLATED BY SAMUEL BUTLER ***\n"
+	body := "Sing, goddess, the wrath of Achilles Peleus' son, the ruinous wrath that brought woes\n"
+
+	job := newJob(t, header+body+footer, header+body+footer)
+	s, _ := New(job, -1, -1)
+	s.Rewrap = 70
+
+	//	deleting the last word of the body marks the first word of the footer changed
+	s.I = s.Edit.Len() - s.editFooter - 1
+	if err := s.Apply(Decision{Action: Delete}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(editingjob.HistorySaved); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(job.LatestEditFile())
+	want := header + strings.Replace(body, " woes", "", 1) + footer
+	if string(content) != want {
+		t.Errorf("\ngot:  %q\nwant: %q", content, want)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	var tests = []struct {
+		choice string
+		want   Decision
+	}{
+		{"a", Decision{Action: Add}},
+		{"ex", Decision{Action: ReplaceSource}},
+		{"me", Decision{Action: Custom}},
+		{"3", Decision{Action: Advance, EditBy: 3}},
+		{"21", Decision{Action: Advance, EditBy: 2, SourceBy: 1}},
+	}
+
+	for _, tt := range tests {
+		if got, err := Command(tt.choice); err != nil || got != tt.want {
+			t.Errorf("%s: got %+v, %v", tt.choice, got, err)
+		}
+	}
+
+	for _, choice := range []string{"", "z", "1a"} {
+		if _, err := Command(choice); err == nil {
+			t.Errorf("%q should not be a command", choice)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package session
+
+import (
+	"poweredit/diff"
+	"sort"
+	"sync"
+)
+
+// tally counts the discrepancies of a session as they are found in the background, so the
+// session doesn't have to wait for the whole of both files to be compared before it starts.
+// It is an estimate, see Left
+type tally struct {
+	mu      sync.Mutex
+	at      []int //	index in the file under edit of each discrepancy found so far, in order
+	done    bool
+	editLen int //	length of the file under edit when the count started
+}
+
+func countDiscrepancies(found <-chan diff.Hunk, editLen int) *tally {
+	t := &tally{editLen: editLen}
+	go func() {
+		for h := range found {
+			t.mu.Lock()
+			t.at = append(t.at, h.A)
+			t.mu.Unlock()
+		}
+		t.mu.Lock()
+		t.done = true
+		t.mu.Unlock()
+	}()
+	return t
+}
+
+// left is how many of the discrepancies found so far are at or after word i of the file under
+// edit, allowing for words added and deleted before i since the count started, and whether
+// all of them have been found
+func (t *tally) left(i, editLen int) (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	shift := editLen - t.editLen
+	n := sort.Search(len(t.at), func(k int) bool { return t.at[k]+shift >= i })
+	return len(t.at) - n, t.done
+}
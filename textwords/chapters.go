@@ -0,0 +1,168 @@
+package textwords
+
+import (
+	"poweredit/diff"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+Chapter is a chapter or book heading, such as "BOOK IV." or
+"CHAPTER XII. THE RETURN". Key is what pairs it with the same heading in
+another text however it is written, eg. "book 4", and Start the index of
+the heading's first word
+*/
+type Chapter struct {
+	Title string
+	Key   string
+	Start int
+}
+
+var (
+	chapterWord   = regexp.MustCompile(`(?i)^(book|chapter|part|canto|volume|section)$`)
+	chapterNumber = regexp.MustCompile(`(?i)^([ivxlcdm]+|\d+|one|two|three|four|five|six|seven|eight|nine|ten|first|second|third|last)[.:]?$`)
+)
+
+// words in a heading line at most, so a sentence starting "Book two..." isn't taken for one
+const headingWords = 10
+
+/*
+Chapters finds the chapter headings of the text: lines with a blank line
+before and after them, starting with a word such as BOOK or CHAPTER
+followed by a number. The lines of a table of contents, which aren't set
+apart by blank lines, aren't headings
+*/
+func (tw *TextWords) Chapters() []Chapter {
+	chapters := []Chapter{}
+
+	for at := 0; at < tw.Len(); at = tw.lineEnd(at) {
+		if c, ok := tw.heading(at); ok {
+			chapters = append(chapters, c)
+		}
+	}
+
+	return chapters
+}
+
+// heading is the chapter heading on the line starting at word at, if it is one
+func (tw *TextWords) heading(at int) (Chapter, bool) {
+	if !chapterWord.MatchString(tw.ws.get(at).W) {
+		return Chapter{}, false
+	}
+
+	next := tw.lineEnd(at)
+	if at+1 < next && next-at <= headingWords &&
+		(at == 0 || paragraphBreak(tw.ws.get(at).lws)) &&
+		(next == tw.Len() || paragraphBreak(tw.ws.get(next).lws)) {
+		if m := chapterNumber.FindStringSubmatch(tw.ws.get(at + 1).W); m != nil {
+			return Chapter{
+				Title: tw.getFlattenedString(at, next-at),
+				Key:   strings.ToLower(tw.ws.get(at).W) + " " + chapterKey(m[1]),
+				Start: at,
+			}, true
+		}
+	}
+	return Chapter{}, false
+}
+
+/*
+NearHeading tells if the word at index at, or a word either side of it, is
+on a chapter heading line, so that changing, adding or deleting a word
+there could change the chapters. Words anywhere else can't, so their
+chapters don't need finding again
+*/
+func (tw *TextWords) NearHeading(at int) bool {
+	for k := max(at-1, 0); k <= at+1 && k < tw.Len(); k++ {
+		//	a heading line is short, so a word far from the start of its line isn't on one
+		start := k
+		for start > 0 && k-start < headingWords && !strings.ContainsAny(tw.ws.get(start).lws, "\n\r") {
+			start--
+		}
+		if _, ok := tw.heading(start); ok {
+			return true
+		}
+	}
+	return false
+}
+
+var numberWords = map[string]int{
+	"one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+	"first": 1, "second": 2, "third": 3,
+}
+
+// chapterKey is the number of a chapter however it is written
+func chapterKey(number string) string {
+	number = strings.ToLower(number)
+	if n, ok := numberWords[number]; ok {
+		return strconv.Itoa(n)
+	}
+	if _, err := strconv.Atoi(number); err == nil {
+		return strings.TrimLeft(number, "0")
+	}
+	if n := roman(number); n > 0 {
+		return strconv.Itoa(n)
+	}
+	return number
+}
+
+// roman reads a roman numeral, or returns 0 if number isn't one
+func roman(number string) int {
+	values := map[rune]int{'i': 1, 'v': 5, 'x': 10, 'l': 50, 'c': 100, 'd': 500, 'm': 1000}
+	n, last := 0, 0
+	for _, r := range reverse(number) {
+		v, ok := values[r]
+		if !ok {
+			return 0
+		}
+		if v < last {
+			n -= v
+		} else {
+			n += v
+			last = v
+		}
+	}
+	return n
+}
+
+/*
+Anchor pairs the start of a chapter in the file under edit with the start
+of the same chapter in the source, word indexes Edit and Source
+*/
+type Anchor struct {
+	Title  string
+	Edit   int
+	Source int
+}
+
+/*
+PairChapters pairs the chapter headings of the file under edit with those
+of the source, in order. A heading that is only in one of the files, say
+one the OCR missed, is left out, rather than throwing off the pairing of
+the headings after it
+*/
+func PairChapters(edit, source *TextWords) []Anchor {
+	editChapters := edit.Chapters()
+	sourceChapters := source.Chapters()
+
+	keys := func(chapters []Chapter) []string {
+		ks := make([]string, len(chapters))
+		for n, c := range chapters {
+			ks[n] = c.Key
+		}
+		return ks
+	}
+
+	anchors := []Anchor{}
+	for _, e := range diff.Compute(keys(editChapters), keys(sourceChapters)) {
+		if e.Kind != diff.Equal {
+			continue
+		}
+		for n := 0; n < e.N; n++ {
+			c := editChapters[e.A+n]
+			anchors = append(anchors, Anchor{Title: c.Title, Edit: c.Start, Source: sourceChapters[e.B+n].Start})
+		}
+	}
+
+	return anchors
+}
@@ -0,0 +1,174 @@
+package textwords
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+Encoding is the character encoding of a text file
+*/
+type Encoding int
+
+const (
+	UTF8 Encoding = iota
+	Windows1252
+	ISO88591
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case Windows1252:
+		return "Windows-1252"
+	case ISO88591:
+		return "ISO-8859-1"
+	default:
+		return "UTF-8"
+	}
+}
+
+/*
+Format is how a text file was stored: its encoding, whether it started
+with a byte order mark and whether its lines ended with CRLF. TextWords
+always holds UTF-8 text with LF line endings, and Format is used to
+write it back the way it was read
+*/
+type Format struct {
+	Encoding Encoding
+	BOM      bool
+	CRLF     bool
+}
+
+func (f Format) String() string {
+	s := f.Encoding.String()
+	if f.BOM {
+		s += " with BOM"
+	}
+	if f.CRLF {
+		s += ", CRLF"
+	}
+	return s
+}
+
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+/*
+DetectFormat works out how b is stored. Anything that isn't valid UTF-8 is
+taken to be Windows-1252 if it uses any of the characters Windows-1252
+adds in 0x80-0x9F, and ISO-8859-1 otherwise. Line endings are CRLF only if
+every line ends with CRLF, so files with mixed line endings are left alone
+*/
+func DetectFormat(b []byte) Format {
+	f := Format{}
+
+	if bytes.HasPrefix(b, bom) {
+		f.BOM = true
+		b = b[len(bom):]
+	}
+
+	if !f.BOM && !utf8.Valid(b) {
+		f.Encoding = ISO88591
+		for _, c := range b {
+			if _, ok := windows1252[c]; ok {
+				f.Encoding = Windows1252
+				break
+			}
+		}
+	}
+
+	lf := bytes.Count(b, []byte("\n"))
+	f.CRLF = lf > 0 && bytes.Count(b, []byte("\r\n")) == lf
+
+	return f
+}
+
+/*
+Decode turns b, stored in format f, into UTF-8 text with LF line endings
+*/
+func Decode(b []byte, f Format) string {
+	if f.BOM {
+		b = bytes.TrimPrefix(b, bom)
+	}
+
+	var txt string
+	switch f.Encoding {
+	case UTF8:
+		txt = string(b)
+	default:
+		s := strings.Builder{}
+		s.Grow(len(b))
+		for _, c := range b {
+			r, ok := windows1252[c]
+			if !ok || f.Encoding == ISO88591 {
+				r = rune(c)
+			}
+			s.WriteRune(r)
+		}
+		txt = s.String()
+	}
+
+	if f.CRLF {
+		txt = strings.ReplaceAll(txt, "\r\n", "\n")
+	}
+
+	return txt
+}
+
+/*
+Encode turns UTF-8 text with LF line endings back into format f. It is an
+error for the text to hold characters the encoding can't represent, such
+as a curly quote typed into an ISO-8859-1 file
+*/
+func Encode(txt string, f Format) ([]byte, error) {
+	if f.CRLF {
+		txt = strings.ReplaceAll(txt, "\n", "\r\n")
+	}
+
+	b := []byte{}
+	if f.BOM {
+		b = append(b, bom...)
+	}
+
+	if f.Encoding == UTF8 {
+		return append(b, txt...), nil
+	}
+
+	for _, r := range txt {
+		c, ok := encodeRune(r, f.Encoding)
+		if !ok {
+			return nil, fmt.Errorf("'%c' can't be written as %s", r, f.Encoding)
+		}
+		b = append(b, c)
+	}
+
+	return b, nil
+}
+
+func encodeRune(r rune, e Encoding) (byte, bool) {
+	if r <= 0xFF {
+		if _, remapped := windows1252[byte(r)]; e == ISO88591 || !remapped {
+			return byte(r), true
+		}
+	}
+
+	if e == Windows1252 {
+		for c, cr := range windows1252 {
+			if cr == r {
+				return c, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// windows1252 maps the bytes in 0x80-0x9F that Windows-1252 uses for printable characters;
+// the rest of Windows-1252 is the same as ISO-8859-1
+var windows1252 = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+	0x88: 'ˆ', 0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž',
+	0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—',
+	0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
@@ -0,0 +1,189 @@
+package textwords
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+/*
+epub is the archive an EPUB was read from. The words of an EPUB are the
+words of the XHTML documents of its spine, in reading order, each document
+starting with a comment naming it so the text can be split back into
+documents when the EPUB is written
+*/
+type epub struct {
+	archive []byte
+	docs    []string          // names of the spine's documents in the archive, in reading order
+	content map[string]string // content of each document as read
+}
+
+var epubDocument = regexp.MustCompile(`<!--poweredit-document:(.*?)-->`)
+
+/*
+Create TextWords from an EPUB, finding its documents through
+META-INF/container.xml and the spine of the package document it points to
+*/
+func FromEPUB(b []byte) (*TextWords, error) {
+	archive, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return &TextWords{}, fmt.Errorf("couldn't read EPUB: %v", err)
+	}
+
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := readXML(archive, "META-INF/container.xml", &container); err != nil {
+		return &TextWords{}, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return &TextWords{}, fmt.Errorf("EPUB container.xml names no package document")
+	}
+	opf := container.Rootfiles[0].FullPath
+
+	var pkg struct {
+		Items []struct {
+			ID        string `xml:"id,attr"`
+			Href      string `xml:"href,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := readXML(archive, opf, &pkg); err != nil {
+		return &TextWords{}, err
+	}
+
+	hrefs := map[string]string{}
+	for _, item := range pkg.Items {
+		if item.MediaType == "application/xhtml+xml" || item.MediaType == "text/html" {
+			hrefs[item.ID] = item.Href
+		}
+	}
+
+	e := &epub{archive: b, content: map[string]string{}}
+	txt := strings.Builder{}
+
+	for _, itemref := range pkg.Spine {
+		href, ok := hrefs[itemref.IDRef]
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		name := path.Join(path.Dir(opf), href)
+
+		content, err := readFile(archive, name)
+		if err != nil {
+			return &TextWords{}, err
+		}
+
+		e.docs = append(e.docs, name)
+		e.content[name] = string(content)
+		fmt.Fprintf(&txt, "<!--poweredit-document:%s-->", name)
+		txt.Write(content)
+	}
+
+	if len(e.docs) == 0 {
+		return &TextWords{}, fmt.Errorf("EPUB spine has no XHTML documents")
+	}
+
+	tw := FromHTML(txt.String())
+	tw.epub = e
+	return tw, nil
+}
+
+// write returns a copy of the EPUB with its documents replaced by those in txt. Every other file,
+// and every document that wasn't changed, is copied from the original archive as it was
+func (e *epub) write(txt string) ([]byte, error) {
+	docs := map[string]string{}
+	marks := epubDocument.FindAllStringSubmatchIndex(txt, -1)
+	for n, mark := range marks {
+		end := len(txt)
+		if n+1 < len(marks) {
+			end = marks[n+1][0]
+		}
+		docs[txt[mark[2]:mark[3]]] = txt[mark[1]:end]
+	}
+
+	for _, name := range e.docs {
+		if _, ok := docs[name]; !ok {
+			return nil, fmt.Errorf("couldn't find %s in the EPUB's text", name)
+		}
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(e.archive), int64(len(e.archive)))
+	if err != nil {
+		return nil, err
+	}
+
+	out := bytes.Buffer{}
+	w := zip.NewWriter(&out)
+
+	for _, f := range archive.File {
+		content, isDoc := docs[f.Name]
+		if !isDoc || content == e.content[f.Name] {
+			if err := copyRaw(w, f); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: f.Name, Method: f.Method, Modified: f.Modified})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(fw, content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+func copyRaw(w *zip.Writer, f *zip.File) error {
+	r, err := f.OpenRaw()
+	if err != nil {
+		return err
+	}
+	fw, err := w.CreateRaw(&f.FileHeader)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
+	return err
+}
+
+func readFile(archive *zip.Reader, name string) ([]byte, error) {
+	f, err := archive.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %s from EPUB: %v", name, err)
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func readXML(archive *zip.Reader, name string, v any) error {
+	content, err := readFile(archive, name)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("couldn't read %s from EPUB: %v", name, err)
+	}
+	return nil
+}
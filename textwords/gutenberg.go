@@ -0,0 +1,243 @@
+package textwords
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	gutenbergStart = regexp.MustCompile(`(?i)^\*\*\*\s*START OF (THE|THIS) PROJECT GUTENBERG E-?BOOK`)
+	gutenbergEnd   = regexp.MustCompile(`(?i)^\*\*\*\s*END OF (THE|THIS) PROJECT GUTENBERG E-?BOOK`)
+)
+
+/*
+GutenbergBody finds the "*** START OF THE PROJECT GUTENBERG EBOOK ... ***"
+and "*** END OF THE PROJECT GUTENBERG EBOOK ... ***" marker lines of a
+Project Gutenberg file, and returns the range of words between them,
+from start up to but not including end. A missing START marker puts start
+at the first word, a missing END marker puts end after the last word, and
+found is false if neither marker is there
+*/
+func (tw *TextWords) GutenbergBody() (start, end int, found bool) {
+	start, end = 0, tw.Len()
+
+	for at := 0; at < tw.Len(); {
+		next := tw.lineEnd(at)
+		line := tw.getFlattenedString(at, next-at)
+
+		if gutenbergStart.MatchString(line) && !found {
+			start = next
+			found = true
+		} else if gutenbergEnd.MatchString(line) && at >= start {
+			end = at
+			found = true
+			break
+		}
+
+		at = next
+	}
+
+	return start, end, found
+}
+
+// lineEnd returns the index of the first word after the line the word at index at is on
+func (tw *TextWords) lineEnd(at int) int {
+	at++
+	for at < tw.Len() && !strings.ContainsAny(tw.ws.get(at).lws, "\n\r") {
+		at++
+	}
+	return at
+}
+
+// the start of a bracketed note, up to the colon ending its heading: [Illustration: ...], [Footnote 3: ...], [** ...]
+var gutenbergNote = regexp.MustCompile(`^\[(\*\*|(?i:illustration|footnote|sidenote|transcriber['’]?s note)((\s+[\w*]{1,4})?:)?)`)
+
+/*
+UseGutenbergMarkup has the words compared under the markup of Project
+Gutenberg transcriptions, none of which is in a scan. The underscores and
+equals signs of _italics_ and =bold= are kept in the word's raw text, and
+put back around the word when it is edited. The headings of
+[Illustration: ...], [Footnote 3: ...] and [** ...] notes, and the
+brackets closing them, are kept in the whitespace between words, and with
+skipBrackets so is everything inside the brackets. It is for plain text
+that hasn't been changed since it was read
+*/
+func (tw *TextWords) UseGutenbergMarkup(skipBrackets bool) {
+	if tw.markup {
+		return
+	}
+
+	txt := tw.t
+	ws := []WordLoc{}
+	pending := ""  // text taken out of words since the last word
+	markupTo := -1 // the words up to here are inside a skipped note
+	noteEnd := -1  // the end of the closing bracket of the note the words are in
+
+	for _, wl := range tw.ws.slice() {
+		start, end := wl.s, wl.s+len(wl.W)
+		before := pending + wl.lws
+		pending = ""
+
+		from, to := max(start, markupTo), end
+
+		//	a note can start part way through a word, as in ruinous[Footnote 3: ...], which ends the word
+		for at := start; noteEnd < 0 && at < end; at++ {
+			if txt[at] != '[' {
+				continue
+			}
+			if m := gutenbergNote.FindStringIndex(txt[at:]); m != nil {
+				if close := closingBracket(txt, at); close >= 0 {
+					noteEnd = close + 1
+					markupTo = at + m[1]
+					if skipBrackets {
+						markupTo = noteEnd
+					}
+					if at > start {
+						to = at
+					} else {
+						from = max(start, markupTo)
+					}
+				}
+			}
+		}
+
+		if noteEnd >= 0 && noteEnd <= end {
+			to = min(to, noteEnd-1)
+			noteEnd = -1
+		}
+
+		if from >= to {
+			pending = before + txt[start:end]
+			continue
+		}
+
+		word := txt[from:to]
+		wl.W = unmark(word)
+		if wl.W != word {
+			wl.raw = word
+		}
+		wl.s = from
+		_, size := utf8.DecodeLastRuneInString(word)
+		wl.e = to - size
+		wl.lws = before + txt[start:from]
+		wl.rws = ""
+		if len(ws) > 0 {
+			ws[len(ws)-1].rws = wl.lws
+		}
+		ws = append(ws, wl)
+		pending = txt[to:end]
+	}
+
+	tw.ws = newRope(ws)
+	tw.tail = pending + tw.tail
+	tw.gutenberg = true
+}
+
+// closingBracket finds the bracket closing the one at txt[at], or -1 if it isn't closed
+func closingBracket(txt string, at int) int {
+	depth := 0
+	for i := at; i < len(txt); i++ {
+		switch txt[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func marker(r rune) bool {
+	return r == '_' || r == '='
+}
+
+// edges splits a word into the characters before its first letter or digit, the characters from
+// there to its last letter or digit, and the characters after. A word without letters or digits
+// is all middle
+func edges(w string) (string, string, string) {
+	first := strings.IndexFunc(w, isAlnum)
+	if first < 0 {
+		return "", w, ""
+	}
+	last := strings.LastIndexFunc(w, isAlnum)
+	_, size := utf8.DecodeRuneInString(w[last:])
+	return w[:first], w[first : last+size], w[last+size:]
+}
+
+func isAlnum(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// unmark takes the _italic_ and =bold= markers off the ends of a word
+func unmark(w string) string {
+	lead, middle, trail := edges(w)
+	return strings.Map(dropMarker, lead) + middle + strings.Map(dropMarker, trail)
+}
+
+func dropMarker(r rune) rune {
+	if marker(r) {
+		return -1
+	}
+	return r
+}
+
+// remark puts the markers at the ends of raw onto w, each as many characters in from
+// the start or end of the word as it was in raw
+func remark(raw, w string) string {
+	lead, _, trail := edges(raw)
+	if lead == "" && trail == "" {
+		return w
+	}
+
+	chars := []rune(w)
+	out := strings.Builder{}
+
+	n := 0
+	for _, r := range lead {
+		if marker(r) {
+			out.WriteRune(r)
+		} else if n < len(chars) {
+			out.WriteRune(chars[n])
+			n++
+		}
+	}
+
+	trailing := []rune(trail)
+	fromEnd := 0
+	for _, r := range trailing {
+		if !marker(r) {
+			fromEnd++
+		}
+	}
+	stop := max(n, len(chars)-fromEnd)
+	out.WriteString(string(chars[n:stop]))
+
+	for _, r := range trailing {
+		if marker(r) {
+			out.WriteRune(r)
+		} else if stop < len(chars) {
+			out.WriteRune(chars[stop])
+			stop++
+		}
+	}
+	out.WriteString(string(chars[stop:]))
+
+	return out.String()
+}
+
+// markers returns the markers at the start and end of a word's raw text
+func markers(raw string) (string, string) {
+	lead, _, trail := edges(raw)
+	keep := func(r rune) rune {
+		if marker(r) {
+			return r
+		}
+		return -1
+	}
+	return strings.Map(keep, lead), strings.Map(keep, trail)
+}
@@ -0,0 +1,249 @@
+package textwords
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+Create TextWords from HTML or XHTML. Only the text is split into words,
+with the markup between words kept in their whitespace, so Text gives back
+the document exactly. Inline tags inside a word, such as
+Achil<i>les</i>, are kept in the word's raw markup, and are put back at
+the same place in the word when it is edited
+*/
+func FromHTML(txt string) *TextWords {
+	wls, tail := parseMarkup(txt)
+	return &TextWords{
+		t:      txt,
+		ws:     newRope(wls),
+		tail:   tail,
+		markup: true,
+	}
+}
+
+// elements whose content is never compared
+var skipped = map[string]bool{"head": true, "script": true, "style": true}
+
+// elements that are part of the flow of a paragraph, so don't separate words
+var inline = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "cite": true, "code": true,
+	"del": true, "dfn": true, "em": true, "i": true, "ins": true, "kbd": true, "mark": true,
+	"q": true, "s": true, "samp": true, "small": true, "span": true, "strong": true,
+	"sub": true, "sup": true, "u": true, "var": true,
+}
+
+func parseMarkup(txt string) ([]WordLoc, string) {
+	wls := []WordLoc{}
+	pending := strings.Builder{} // whitespace and markup before the next word
+	raw := strings.Builder{}
+	w := strings.Builder{}
+	wl := WordLoc{}
+	inWord := false
+
+	endWord := func() {
+		if !inWord {
+			return
+		}
+		wl.W = w.String()
+		wl.raw = raw.String()
+		wls = append(wls, wl)
+		w.Reset()
+		raw.Reset()
+		wl = WordLoc{}
+		inWord = false
+	}
+
+	for i := 0; i < len(txt); {
+		if txt[i] == '<' {
+			end, name := markupEnd(txt, i)
+			if inWord && inline[name] {
+				raw.WriteString(txt[i:end])
+			} else {
+				endWord()
+				pending.WriteString(txt[i:end])
+			}
+			i = end
+			continue
+		}
+
+		char, size := utf8.DecodeRuneInString(txt[i:])
+		text := txt[i : i+size]
+		decoded := text
+		if char == '&' {
+			text, decoded = entity(txt, i)
+			char, _ = utf8.DecodeRuneInString(decoded)
+		}
+
+		//	a byte order mark at the start of a document isn't part of any word
+		if unicode.IsSpace(char) || char == '\uFEFF' {
+			endWord()
+			pending.WriteString(text)
+		} else {
+			if !inWord {
+				inWord = true
+				wl.s = i
+				wl.lws = pending.String()
+				if len(wls) > 0 {
+					wls[len(wls)-1].rws = pending.String()
+				}
+				pending.Reset()
+			}
+			raw.WriteString(text)
+			w.WriteString(decoded)
+			wl.e = i
+		}
+		i += len(text)
+	}
+	endWord()
+
+	return wls, pending.String()
+}
+
+// markupEnd finds the end of the tag, comment or other markup starting at txt[at], and the name
+// of the tag. The content of skipped elements is taken as part of their opening tag
+func markupEnd(txt string, at int) (int, string) {
+	rest := txt[at:]
+
+	for _, delims := range [][2]string{{"<!--", "-->"}, {"<![CDATA[", "]]>"}, {"<?", "?>"}} {
+		if strings.HasPrefix(rest, delims[0]) {
+			if end := strings.Index(rest[len(delims[0]):], delims[1]); end >= 0 {
+				return at + len(delims[0]) + end + len(delims[1]), ""
+			}
+			return len(txt), ""
+		}
+	}
+
+	end := len(txt)
+	quote := byte(0)
+	for i := 1; i < len(rest); i++ {
+		if quote != 0 {
+			if rest[i] == quote {
+				quote = 0
+			}
+		} else if rest[i] == '"' || rest[i] == '\'' {
+			quote = rest[i]
+		} else if rest[i] == '>' {
+			end = at + i + 1
+			break
+		}
+	}
+
+	tag := txt[at:end]
+	name := strings.TrimLeft(tag, "</!")
+	if i := strings.IndexFunc(name, func(r rune) bool { return unicode.IsSpace(r) || r == '>' || r == '/' }); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.ToLower(name)
+
+	if skipped[name] && !strings.HasPrefix(tag, "</") && !strings.HasSuffix(tag, "/>") {
+		if close := strings.Index(strings.ToLower(txt[end:]), "</"+name); close >= 0 {
+			if gt := strings.IndexByte(txt[end+close:], '>'); gt >= 0 {
+				return end + close + gt + 1, name
+			}
+		}
+		return len(txt), name
+	}
+
+	return end, name
+}
+
+// entity reads the character reference starting at txt[at], returning it as written and decoded.
+// An ampersand that doesn't start a reference is just an ampersand
+func entity(txt string, at int) (string, string) {
+	end := strings.IndexByte(txt[at:min(len(txt), at+12)], ';')
+	if end < 0 {
+		return "&", "&"
+	}
+	ref := txt[at : at+end+1]
+	decoded := html.UnescapeString(ref)
+	if decoded == ref {
+		return "&", "&"
+	}
+	return ref, decoded
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// wordText is a word as it is written out: its raw markup if it hasn't been changed,
+// otherwise the new word with any tags that were inside it put back in place
+func (tw *TextWords) wordText(wl WordLoc) string {
+	if tw.alto {
+		return altoText(wl)
+	}
+	if tw.gutenberg {
+		return remark(wl.raw, wl.W)
+	}
+
+	if wl.raw == "" {
+		if tw.markup {
+			return escaper.Replace(wl.W)
+		}
+		return wl.W
+	}
+
+	ws, _ := parseMarkup(wl.raw)
+	if len(ws) == 1 && ws[0].W == wl.W {
+		return wl.raw
+	}
+
+	return retag(wl.raw, wl.W)
+}
+
+// retag writes w with the tags found in raw at the same character positions they had in raw
+func retag(raw, w string) string {
+	type tag struct {
+		at     int
+		markup string
+	}
+	tags := []tag{}
+	n := 0
+	for i := 0; i < len(raw); {
+		if raw[i] == '<' {
+			end, _ := markupEnd(raw, i)
+			tags = append(tags, tag{n, raw[i:end]})
+			i = end
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(raw[i:])
+		text := raw[i : i+size]
+		if raw[i] == '&' {
+			text, _ = entity(raw, i)
+		}
+		n++
+		i += len(text)
+	}
+
+	//	tags after the last character, such as a closing tag, stay after the last character
+	out := strings.Builder{}
+	k := 0
+	for at, char := range []rune(w) {
+		for k < len(tags) && tags[k].at <= at && tags[k].at < n {
+			out.WriteString(tags[k].markup)
+			k++
+		}
+		out.WriteString(escaper.Replace(string(char)))
+	}
+	for ; k < len(tags); k++ {
+		out.WriteString(tags[k].markup)
+	}
+
+	return out.String()
+}
+
+// tagsOf returns just the markup of a word's raw markup
+func tagsOf(raw string) string {
+	tags := strings.Builder{}
+	for i := 0; i < len(raw); {
+		if raw[i] == '<' {
+			end, _ := markupEnd(raw, i)
+			tags.WriteString(raw[i:end])
+			i = end
+			continue
+		}
+		i++
+	}
+	return tags.String()
+}
@@ -0,0 +1,303 @@
+package textwords
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*
+OCR is what an OCR engine recorded about a word: how confident it was of
+the word, from 0 to 1, where the word is on the page images, and the
+bounding box of the word on its page as x0, y0, x1, y1
+*/
+type OCR struct {
+	Confidence float64 // -1 if the engine didn't record one
+	Page       int     // counted from 1
+	Line       int     // counted from 1 on each page
+	Box        [4]int
+}
+
+/*
+OCR returns what the OCR engine recorded about the word, or nil for a
+word that wasn't read from hOCR or ALTO
+*/
+func (wl WordLoc) OCR() *OCR {
+	return wl.ocr
+}
+
+/*
+Create TextWords from hOCR, the HTML output of OCR engines such as
+Tesseract. It is read as HTML, and each word keeps the confidence and
+position recorded in the title of its ocrx_word element
+*/
+func FromHOCR(txt string) *TextWords {
+	tw := FromHTML(txt)
+
+	page, line := 0, 0
+	var word *OCR
+
+	tw.ws.each(0, tw.Len(), func(_ int, wl *WordLoc) {
+		for _, tag := range tags(wl.lws) {
+			class := strings.Fields(attr(tag, "class"))
+			switch {
+			case slices.Contains(class, "ocr_page"):
+				page++
+				line = 0
+			case slices.ContainsFunc(class, hocrLine):
+				line++
+			case slices.Contains(class, "ocrx_word"):
+				word = hocrWord(attr(tag, "title"))
+			}
+		}
+
+		if word != nil {
+			word.Page = max(page, 1)
+			word.Line = max(line, 1)
+			wl.ocr = word
+			word = nil
+		}
+	})
+
+	return tw
+}
+
+// hOCR classes of the elements that lay out a line of text
+func hocrLine(class string) bool {
+	switch class {
+	case "ocr_line", "ocrx_line", "ocr_header", "ocr_caption", "ocr_textfloat":
+		return true
+	}
+	return false
+}
+
+var (
+	hocrBox  = regexp.MustCompile(`bbox (\d+) (\d+) (\d+) (\d+)`)
+	hocrConf = regexp.MustCompile(`x_wconf ([\d.]+)`)
+)
+
+// hocrWord reads the properties in the title of an ocrx_word element, eg. "bbox 10 20 80 40; x_wconf 93"
+func hocrWord(title string) *OCR {
+	word := &OCR{Confidence: -1}
+
+	if m := hocrBox.FindStringSubmatch(title); m != nil {
+		for n := range word.Box {
+			word.Box[n], _ = strconv.Atoi(m[n+1])
+		}
+	}
+	if m := hocrConf.FindStringSubmatch(title); m != nil {
+		if conf, err := strconv.ParseFloat(m[1], 64); err == nil {
+			word.Confidence = conf / 100
+		}
+	}
+
+	return word
+}
+
+/*
+Create TextWords from ALTO XML, whose words are the CONTENT of its String
+elements. Everything else in the document is kept as markup between the
+words, and each word keeps its String element, so edits are written back
+into its CONTENT and deleted words take their String element with them.
+XML that has neither an alto root element nor any String elements isn't
+ALTO, and is an error rather than a text with no words
+*/
+func FromALTO(txt string) (*TextWords, error) {
+	wls := []WordLoc{}
+	pending := strings.Builder{}
+	page, line := 0, 0
+	root := ""
+
+	for i := 0; i < len(txt); {
+		if txt[i] != '<' {
+			next := strings.IndexByte(txt[i:], '<')
+			if next < 0 {
+				next = len(txt) - i
+			}
+			pending.WriteString(txt[i : i+next])
+			i += next
+			continue
+		}
+
+		end, _ := markupEnd(txt, i)
+		tag := txt[i:end]
+
+		name := element(tag)
+		if root == "" {
+			root = name
+		}
+
+		switch name {
+		case "Page":
+			page++
+			line = 0
+		case "TextLine":
+			line++
+		case "String":
+			content, ocr, err := altoString(tag)
+			if err != nil {
+				return &TextWords{}, err
+			}
+			ocr.Page = max(page, 1)
+			ocr.Line = max(line, 1)
+
+			wl := WordLoc{W: content, s: i, e: end - 1, lws: pending.String(), raw: tag, ocr: ocr}
+			if len(wls) > 0 {
+				wls[len(wls)-1].rws = wl.lws
+			}
+			wls = append(wls, wl)
+			pending.Reset()
+			i = end
+			continue
+		}
+
+		pending.WriteString(tag)
+		i = end
+	}
+
+	if root != "alto" && len(wls) == 0 {
+		return &TextWords{}, fmt.Errorf("not an ALTO document: no alto root element or String elements")
+	}
+
+	return &TextWords{t: txt, ws: newRope(wls), tail: pending.String(), markup: true, alto: true}, nil
+}
+
+// altoString reads the word and what the OCR engine recorded about it from a String element
+func altoString(tag string) (string, *OCR, error) {
+	token, err := xml.NewDecoder(strings.NewReader(tag)).Token()
+	if err != nil {
+		return "", nil, fmt.Errorf("couldn't read ALTO element %s: %v", tag, err)
+	}
+
+	start, ok := token.(xml.StartElement)
+	if !ok {
+		return "", nil, fmt.Errorf("couldn't read ALTO element %s", tag)
+	}
+
+	ocr := &OCR{Confidence: -1}
+	content := ""
+	var hpos, vpos, width, height int
+
+	for _, a := range start.Attr {
+		switch a.Name.Local {
+		case "CONTENT":
+			content = a.Value
+		case "WC":
+			if wc, err := strconv.ParseFloat(a.Value, 64); err == nil {
+				ocr.Confidence = wc
+			}
+		case "HPOS":
+			hpos = int(parseNumber(a.Value))
+		case "VPOS":
+			vpos = int(parseNumber(a.Value))
+		case "WIDTH":
+			width = int(parseNumber(a.Value))
+		case "HEIGHT":
+			height = int(parseNumber(a.Value))
+		}
+	}
+	ocr.Box = [4]int{hpos, vpos, hpos + width, vpos + height}
+
+	return content, ocr, nil
+}
+
+// altoText writes a word back into its String element
+func altoText(wl WordLoc) string {
+	content := xmlEscape(wl.W)
+	if wl.raw == "" {
+		return `<String CONTENT="` + content + `"/>`
+	}
+
+	m := altoContent.FindStringSubmatchIndex(wl.raw)
+	if m == nil {
+		return wl.raw
+	}
+	value, _, _ := altoString(wl.raw)
+	if value == wl.W {
+		return wl.raw
+	}
+
+	//	the value is the first group if double quoted, the second if single quoted
+	from, to := m[2], m[3]
+	if from < 0 {
+		from, to = m[4], m[5]
+	}
+	return wl.raw[:from] + content + wl.raw[to:]
+}
+
+var altoSpace = regexp.MustCompile(`^\s*(<(\w+:)?SP\b[^>]*/>\s*)*$`)
+
+var altoContent = regexp.MustCompile(`\bCONTENT\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+func xmlEscape(s string) string {
+	b := strings.Builder{}
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func parseNumber(s string) float64 {
+	n, _ := strconv.ParseFloat(s, 64)
+	return n
+}
+
+// element is the local name of the element an opening tag starts, or "" for any other markup
+func element(tag string) string {
+	if strings.HasPrefix(tag, "</") || strings.HasPrefix(tag, "<!") || strings.HasPrefix(tag, "<?") {
+		return ""
+	}
+	name := strings.TrimPrefix(tag, "<")
+	if i := strings.IndexAny(name, " \t\r\n/>"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// tags lists the opening tags in markup
+func tags(markup string) []string {
+	ts := []string{}
+	for i := 0; i < len(markup); {
+		if markup[i] != '<' {
+			i++
+			continue
+		}
+		end, _ := markupEnd(markup, i)
+		if element(markup[i:end]) != "" {
+			ts = append(ts, markup[i:end])
+		}
+		i = end
+	}
+	return ts
+}
+
+// attr reads an attribute of an opening tag, which may be HTML rather than XML
+func attr(tag, name string) string {
+	d := xml.NewDecoder(strings.NewReader(closeTag(tag)))
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	token, err := d.Token()
+	if err != nil {
+		return ""
+	}
+	if start, ok := token.(xml.StartElement); ok {
+		for _, a := range start.Attr {
+			if a.Name.Local == name {
+				return a.Value
+			}
+		}
+	}
+	return ""
+}
+
+// closeTag makes an HTML opening tag well formed enough for the XML decoder to read its attributes
+func closeTag(tag string) string {
+	if strings.HasSuffix(tag, "/>") {
+		return tag
+	}
+	return strings.TrimSuffix(tag, ">") + "/>"
+}
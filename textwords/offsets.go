@@ -0,0 +1,103 @@
+package textwords
+
+import (
+	"unicode/utf8"
+)
+
+/*
+Offset returns the byte offset in Text of the first character of the word
+at index at, as the text is after any edits. Offsets are into the text as
+held, UTF-8 with LF line endings, whatever the format of the file it was
+read from
+*/
+func (tw *TextWords) Offset(at int) int {
+	return tw.ws.prefix(at, tw.piece).bytes + len(tw.ws.get(at).lws)
+}
+
+/*
+End returns the byte offset in Text just past the last character of the
+word at index at, so the word, with any markup inside it, is
+Text()[Offset(at):End(at)]
+*/
+func (tw *TextWords) End(at int) int {
+	return tw.ws.prefix(at+1, tw.piece).bytes
+}
+
+/*
+FileOffset and FileEnd are Offset and End as byte offsets into the file
+Bytes writes, in the encoding and line endings the text was read with,
+which is the file it was read from as long as the text hasn't been edited.
+They aren't offsets into an EPUB, whose text is spread over its documents
+*/
+func (tw *TextWords) FileOffset(at int) int {
+	return tw.fileBytes(tw.ws.prefix(at, tw.piece).then(measureOf(tw.ws.get(at).lws)))
+}
+
+func (tw *TextWords) FileEnd(at int) int {
+	return tw.fileBytes(tw.ws.prefix(at+1, tw.piece))
+}
+
+// fileBytes is how many bytes text measuring m takes up in the format of the file
+func (tw *TextWords) fileBytes(m measure) int {
+	n := m.bytes
+	if tw.format.Encoding != UTF8 {
+		n = m.runes
+	}
+	if tw.format.CRLF {
+		n += m.lines
+	}
+	if tw.format.BOM {
+		n += len(bom)
+	}
+	return n
+}
+
+/*
+Position returns the line and column of the first character of the word
+at index at in Text, both counted from 1, with columns in characters
+*/
+func (tw *TextWords) Position(at int) (line, col int) {
+	m := tw.ws.prefix(at, tw.piece).then(measureOf(tw.ws.get(at).lws))
+	return m.lines + 1, m.tail + 1
+}
+
+/*
+AtOffset returns the index of the word at byte offset offset in Text, or of
+the word after it if the offset is in the whitespace or markup before a
+word. An offset past the last word gives Len
+*/
+func (tw *TextWords) AtOffset(offset int) int {
+	return tw.ws.atByte(max(offset, 0), tw.piece)
+}
+
+/*
+AtPosition returns the index of the word at line line and column col of
+Text, counted from 1, as AtOffset does for an offset. A position past
+the end of its line gives the first word after the line, and a line past
+the end of the text gives Len
+*/
+func (tw *TextWords) AtPosition(line, col int) int {
+	offset := tw.ws.lineStart(line, tw.piece)
+	if offset < 0 {
+		return tw.Len()
+	}
+
+	//	walk along the line from its start to the column
+	for at, left := tw.AtOffset(offset), col-1; at < tw.Len() && left > 0; at++ {
+		from := offset - tw.ws.prefix(at, tw.piece).bytes
+		rest := tw.piece(tw.ws.get(at))[max(from, 0):]
+		for _, char := range rest {
+			if char == '\n' || left == 0 {
+				return tw.AtOffset(offset)
+			}
+			offset += utf8.RuneLen(char)
+			left--
+		}
+	}
+	return tw.AtOffset(offset)
+}
+
+// piece is the text a word takes up in Text: its whitespace and markup before it, and the word
+func (tw *TextWords) piece(wl WordLoc) string {
+	return wl.lws + tw.wordText(wl)
+}
@@ -0,0 +1,79 @@
+package textwords
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+Page returns the page of the scanned book the word is on, or 0 if that
+isn't known. Pages come from hOCR and ALTO, from the form feeds between
+pages of plain text, or from the lines holding just a page number in
+Internet Archive _djvu.txt files
+*/
+func (wl WordLoc) Page() int {
+	if wl.ocr != nil {
+		return wl.ocr.Page
+	}
+	return wl.page
+}
+
+/*
+numberPages works out the page each word is on. Form feeds separate the
+pages of a scan, the first being page 1. Without form feeds, a _djvu.txt
+file's pages can still be found from the lines holding nothing but a page
+number, as long as there are enough of them counting up to be sure they
+are page numbers
+*/
+func (tw *TextWords) numberPages(djvu bool) {
+	if strings.Contains(tw.t, "\f") {
+		page := 1
+		tw.ws.each(0, tw.Len(), func(_ int, wl *WordLoc) {
+			page += strings.Count(wl.lws, "\f")
+			wl.page = page
+		})
+		return
+	}
+
+	if !djvu {
+		return
+	}
+
+	type numberLine struct{ at, page int }
+	lines := []numberLine{}
+
+	wls := tw.ws.slice()
+	for at, wl := range wls {
+		startsLine := at == 0 || strings.Contains(wl.lws, "\n")
+		endsLine := at == len(wls)-1 || strings.Contains(wls[at+1].lws, "\n")
+		if !startsLine || !endsLine {
+			continue
+		}
+
+		page, err := strconv.Atoi(wl.W)
+		if err != nil || page <= 0 {
+			continue
+		}
+		if len(lines) > 0 && page <= lines[len(lines)-1].page {
+			continue
+		}
+		lines = append(lines, numberLine{at, page})
+	}
+
+	if len(lines) < minPageNumbers {
+		return
+	}
+
+	//	a page number heads its page, and the words before the first one are on the page before
+	page := lines[0].page - 1
+	tw.ws.each(0, tw.Len(), func(at int, wl *WordLoc) {
+		if len(lines) > 0 && lines[0].at == at {
+			page = lines[0].page
+			lines = lines[1:]
+		}
+		wl.page = page
+	})
+}
+
+// fewer page number lines than this could just as well be numbered sections or lists
+const minPageNumbers = 3
@@ -0,0 +1,82 @@
+package textwords
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+SplitPunctuation makes the punctuation at the start and end of each word
+tokens of their own, one for each mark, so "Troy," against "Troy;" is a
+discrepancy in just the comma. A mark is attached to the token before or
+after it by having no whitespace between them, so the text is written out
+exactly as it was. Words made of nothing but punctuation, such as "***",
+are left whole, and underscores and equals signs are left in words for
+UseGutenbergMarkup, which is to be used after SplitPunctuation. It is for
+plain text that hasn't been changed since it was read
+*/
+func (tw *TextWords) SplitPunctuation() {
+	if tw.markup || tw.gutenberg {
+		return
+	}
+
+	ws := []WordLoc{}
+	for _, wl := range tw.ws.slice() {
+		ws = append(ws, splitWord(wl)...)
+	}
+	for k := 1; k < len(ws); k++ {
+		ws[k-1].rws = ws[k].lws
+	}
+
+	tw.ws = newRope(ws)
+}
+
+func punctuation(r rune) bool {
+	return unicode.IsPunct(r) && r != '_'
+}
+
+// splitWord splits the punctuation off the start and end of a word
+func splitWord(wl WordLoc) []WordLoc {
+	w := wl.W
+	first := len(w)
+	for k, r := range w {
+		if !punctuation(r) {
+			first = k
+			break
+		}
+	}
+	if first == len(w) {
+		return []WordLoc{wl}
+	}
+
+	last := len(w)
+	for last > first {
+		r, size := utf8.DecodeLastRuneInString(w[:last])
+		if !punctuation(r) {
+			break
+		}
+		last -= size
+	}
+
+	token := func(from, to int, lws string) WordLoc {
+		_, size := utf8.DecodeLastRuneInString(w[from:to])
+		return WordLoc{W: w[from:to], s: wl.s + from, e: wl.s + to - size, lws: lws, page: wl.page}
+	}
+
+	tokens := []WordLoc{}
+	lws := wl.lws
+	for k := 0; k < first; {
+		_, size := utf8.DecodeRuneInString(w[k:])
+		tokens = append(tokens, token(k, k+size, lws))
+		lws = ""
+		k += size
+	}
+	tokens = append(tokens, token(first, last, lws))
+	for k := last; k < len(w); {
+		_, size := utf8.DecodeRuneInString(w[k:])
+		tokens = append(tokens, token(k, k+size, ""))
+		k += size
+	}
+
+	return tokens
+}
@@ -0,0 +1,186 @@
+package textwords
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+Rewrap reflows the paragraphs holding words that were added, edited or
+deleted next to, so no line is longer than width where it can be helped.
+Lines are only changed from the first one that is too long, and only
+until the line breaks fall back in step with the original ones, so diffs
+against the original stay small. Paragraphs with indented lines, and
+poetry, whose lines are mostly well short of width, are left alone, as
+is markup, whose line breaks are up to the markup, and paragraphs holding
+Gutenberg notes. Only the words from index from up to but not including to
+are rewrapped, so a Project Gutenberg header and footer outside the body
+are kept as they are, even when the words either side of them changed
+*/
+func (tw *TextWords) Rewrap(width, from, to int) {
+	if tw.markup {
+		return
+	}
+
+	to = min(to, tw.Len())
+	for start := max(from, 0); start < to; {
+		end := start + 1
+		for end < to && !paragraphBreak(tw.ws.get(end).lws) {
+			end++
+		}
+
+		if tw.dirty(start, end) && !tw.verse(start, end, width) && !tw.notes(start, end) {
+			tw.reflow(start, end, width)
+		}
+
+		start = end
+	}
+}
+
+func (tw *TextWords) dirty(start, end int) bool {
+	dirty := false
+	tw.ws.read(start, end, func(_ int, wl WordLoc) {
+		dirty = dirty || wl.dirty
+	})
+	return dirty
+}
+
+// verse tells if a paragraph is indented or poetry, whose line breaks mean something
+func (tw *TextWords) verse(start, end, width int) bool {
+	lines := tw.lines(start, end)
+
+	for _, line := range lines {
+		if indent(tw.ws.get(line[0]).lws) != "" {
+			return true
+		}
+	}
+
+	//	judge by the lines that haven't been changed, leaving out the last which is usually short
+	total, n := 0, 0
+	for _, line := range lines[:len(lines)-1] {
+		if !tw.dirty(line[0], line[1]) {
+			total += tw.lineLen(line[0], line[1])
+			n++
+		}
+	}
+	return n > 0 && total/n < width/2
+}
+
+// notes tells if a paragraph holds Gutenberg markup kept between its words, which rewrapping would lose
+func (tw *TextWords) notes(start, end int) bool {
+	for at := start + 1; at < end; at++ {
+		if tw.hasMarkup(tw.ws.get(at).lws) {
+			return true
+		}
+	}
+	return false
+}
+
+// lines splits the paragraph into the ranges of words on each of its lines
+func (tw *TextWords) lines(start, end int) [][2]int {
+	lines := [][2]int{}
+	for at := start; at < end; {
+		next := min(tw.lineEnd(at), end)
+		lines = append(lines, [2]int{at, next})
+		at = next
+	}
+	return lines
+}
+
+// lineLen is the length in characters of the words from start to end laid out on one line
+func (tw *TextWords) lineLen(start, end int) int {
+	n := 0
+	for at := start; at < end; at++ {
+		if at > start {
+			n += utf8.RuneCountInString(spacing(tw.ws.get(at).lws))
+		}
+		n += utf8.RuneCountInString(tw.ws.get(at).W)
+	}
+	return n
+}
+
+func (tw *TextWords) reflow(start, end, width int) {
+	carried := []int{}
+
+	for _, line := range tw.lines(start, end) {
+		//	back in step with the original line breaks, so leave the line as it is
+		if len(carried) == 0 && tw.lineLen(line[0], line[1]) <= width {
+			continue
+		}
+
+		words := carried
+		for at := line[0]; at < line[1]; at++ {
+			words = append(words, at)
+		}
+
+		//	fill lines, then carry the last one, which may not be full, onto the next line
+		last := 0
+		col := utf8.RuneCountInString(tw.ws.get(words[0]).W)
+		for n, at := range words[1:] {
+			length := utf8.RuneCountInString(tw.ws.get(at).W)
+			space := utf8.RuneCountInString(spacing(tw.ws.get(at).lws))
+
+			//	punctuation split off a word can't go on a line of its own
+			if tw.ws.get(at).lws == "" {
+				col += length
+				continue
+			}
+
+			if col+space+length > width {
+				tw.breakBefore(at)
+				last = n + 1
+				col = length
+			} else {
+				tw.joinBefore(at)
+				col += space + length
+			}
+		}
+
+		carried = []int{}
+		if last > 0 {
+			carried = append(carried, words[last:]...)
+		}
+	}
+}
+
+// breakBefore starts a new line at the word at index at
+func (tw *TextWords) breakBefore(at int) {
+	if !strings.ContainsAny(tw.ws.get(at).lws, "\n\r") {
+		tw.setLws(at, "\n")
+	}
+}
+
+// joinBefore puts the word at index at on the same line as the word before it
+func (tw *TextWords) joinBefore(at int) {
+	if strings.ContainsAny(tw.ws.get(at).lws, "\n\r") {
+		tw.setLws(at, " ")
+	}
+}
+
+func (tw *TextWords) setLws(at int, lws string) {
+	tw.ws.at(at).lws = lws
+	if at > 0 {
+		tw.ws.at(at - 1).rws = lws
+	}
+}
+
+// a blank line separates paragraphs
+func paragraphBreak(lws string) bool {
+	return strings.Count(lws, "\n") >= 2 || strings.Count(lws, "\r") >= 2
+}
+
+// indent is the whitespace a word starting a line is indented by
+func indent(lws string) string {
+	if i := strings.LastIndexAny(lws, "\n\r"); i >= 0 {
+		return lws[i+1:]
+	}
+	return ""
+}
+
+// spacing is the whitespace between two words on the same line, or a single space for a line break
+func spacing(lws string) string {
+	if strings.ContainsAny(lws, "\n\r") {
+		return " "
+	}
+	return lws
+}
@@ -0,0 +1,310 @@
+package textwords
+
+import (
+	"math/rand"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+rope holds the words of a text in a treap ordered by position, each node
+keeping the number of words under it, so a word can be found, added or
+deleted by its index in O(log n) rather than shifting every word after it.
+Each node also measures the text of the words under it, so the offset and
+line of any word can be found in O(log n). Measures are brought up to date
+when next needed, after words are changed through at or each
+*/
+type rope struct {
+	root *node
+}
+
+type node struct {
+	wl          WordLoc
+	priority    uint32
+	size        int
+	left, right *node
+	own, sum    measure // of the node's word, and of all the words under it
+	stale       bool    // the measures need bringing up to date
+}
+
+// measure is the length of a piece of text, and the number of characters after its last line break,
+// or all of them if it has none
+type measure struct {
+	bytes, runes, lines, tail int
+}
+
+func measureOf(s string) measure {
+	m := measure{bytes: len(s), runes: utf8.RuneCountInString(s), lines: strings.Count(s, "\n")}
+	m.tail = m.runes
+	if m.lines > 0 {
+		m.tail = utf8.RuneCountInString(s[strings.LastIndexByte(s, '\n')+1:])
+	}
+	return m
+}
+
+// then measures the text of a followed by the text of b
+func (a measure) then(b measure) measure {
+	m := measure{bytes: a.bytes + b.bytes, runes: a.runes + b.runes, lines: a.lines + b.lines, tail: a.tail + b.runes}
+	if b.lines > 0 {
+		m.tail = b.tail
+	}
+	return m
+}
+
+func sum(n *node) measure {
+	if n == nil {
+		return measure{}
+	}
+	return n.sum
+}
+
+func size(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *node) update() {
+	n.size = 1 + size(n.left) + size(n.right)
+	n.stale = true
+}
+
+// refresh brings the measures of the stale nodes under n up to date, piece being the text of a word
+func refresh(n *node, piece func(WordLoc) string) {
+	if n == nil || !n.stale {
+		return
+	}
+	refresh(n.left, piece)
+	refresh(n.right, piece)
+	n.own = measureOf(piece(n.wl))
+	n.sum = sum(n.left).then(n.own).then(sum(n.right))
+	n.stale = false
+}
+
+// newRope builds a rope of the words in O(n), as the treap of their random priorities
+func newRope(wls []WordLoc) *rope {
+	stack := []*node{}
+	for _, wl := range wls {
+		n := &node{wl: wl, priority: rand.Uint32(), size: 1, stale: true}
+
+		//	the nodes of lower priority on the right edge of the tree go under the new node
+		var last *node
+		for len(stack) > 0 && stack[len(stack)-1].priority < n.priority {
+			last = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			last.update()
+		}
+		n.left = last
+		if len(stack) > 0 {
+			stack[len(stack)-1].right = n
+		}
+		stack = append(stack, n)
+	}
+
+	for k := len(stack) - 1; k >= 0; k-- {
+		stack[k].update()
+	}
+
+	r := &rope{}
+	if len(stack) > 0 {
+		r.root = stack[0]
+	}
+	return r
+}
+
+// len is 0 for the nil rope of an empty TextWords
+func (r *rope) len() int {
+	if r == nil {
+		return 0
+	}
+	return size(r.root)
+}
+
+// at returns the word at index i, to be changed in place through the pointer
+func (r *rope) at(i int) *WordLoc {
+	return &r.find(i, true).wl
+}
+
+// get returns a copy of the word at index i
+func (r *rope) get(i int) WordLoc {
+	return r.find(i, false).wl
+}
+
+func (r *rope) find(i int, changing bool) *node {
+	n := r.root
+	for n != nil {
+		n.stale = n.stale || changing
+		left := size(n.left)
+		switch {
+		case i < left:
+			n = n.left
+		case i == left:
+			return n
+		default:
+			i -= left + 1
+			n = n.right
+		}
+	}
+	panic("textwords: word index out of range")
+}
+
+func (r *rope) insert(i int, wl WordLoc) {
+	left, right := split(r.root, i)
+	r.root = merge(merge(left, &node{wl: wl, priority: rand.Uint32(), size: 1, stale: true}), right)
+}
+
+func (r *rope) remove(i int) {
+	left, right := split(r.root, i)
+	_, right = split(right, 1)
+	r.root = merge(left, right)
+}
+
+// each calls fn with each word from index from up to but not including to, in order, to be
+// read or changed in place through the pointer
+func (r *rope) each(from, to int, fn func(at int, wl *WordLoc)) {
+	r.walk(from, to, true, fn)
+}
+
+// read calls fn with a copy of each word from index from up to but not including to, in order
+func (r *rope) read(from, to int, fn func(at int, wl WordLoc)) {
+	r.walk(from, to, false, func(at int, wl *WordLoc) { fn(at, *wl) })
+}
+
+func (r *rope) walk(from, to int, changing bool, fn func(at int, wl *WordLoc)) {
+	if r == nil {
+		return
+	}
+	var walk func(n *node, offset int)
+	walk = func(n *node, offset int) {
+		if n == nil || offset >= to || offset+n.size <= from {
+			return
+		}
+		n.stale = n.stale || changing
+		at := offset + size(n.left)
+		walk(n.left, offset)
+		if at >= from && at < to {
+			fn(at, &n.wl)
+		}
+		walk(n.right, at+1)
+	}
+	walk(r.root, 0)
+}
+
+// slice returns a copy of the words
+func (r *rope) slice() []WordLoc {
+	wls := make([]WordLoc, 0, r.len())
+	r.read(0, r.len(), func(_ int, wl WordLoc) {
+		wls = append(wls, wl)
+	})
+	return wls
+}
+
+// prefix measures the text of the words before index i
+func (r *rope) prefix(i int, piece func(WordLoc) string) measure {
+	refresh(r.root, piece)
+	m := measure{}
+	for n := r.root; n != nil; {
+		left := size(n.left)
+		if i <= left {
+			n = n.left
+			continue
+		}
+		m = m.then(sum(n.left)).then(n.own)
+		i -= left + 1
+		n = n.right
+	}
+	return m
+}
+
+// atByte returns the index of the word whose text holds the byte at offset, or the number of
+// words if the offset is past them
+func (r *rope) atByte(offset int, piece func(WordLoc) string) int {
+	refresh(r.root, piece)
+	at := 0
+	for n := r.root; n != nil; {
+		left := sum(n.left).bytes
+		switch {
+		case offset < left:
+			n = n.left
+		case offset < left+n.own.bytes:
+			return at + size(n.left)
+		default:
+			offset -= left + n.own.bytes
+			at += size(n.left) + 1
+			n = n.right
+		}
+	}
+	return at
+}
+
+// lineStart returns the byte offset at which line number line, counted from 1, starts, or -1
+// if the words don't have that many lines
+func (r *rope) lineStart(line int, piece func(WordLoc) string) int {
+	refresh(r.root, piece)
+	breaks := line - 1
+	if breaks <= 0 {
+		return 0
+	}
+
+	offset := 0
+	for n := r.root; n != nil; {
+		left := sum(n.left)
+		switch {
+		case breaks <= left.lines:
+			n = n.left
+		case breaks <= left.lines+n.own.lines:
+			text := piece(n.wl)
+			breaks -= left.lines
+			offset += left.bytes
+			for k := 0; k < len(text); k++ {
+				if text[k] == '\n' {
+					breaks--
+					if breaks == 0 {
+						return offset + k + 1
+					}
+				}
+			}
+			return -1
+		default:
+			breaks -= left.lines + n.own.lines
+			offset += left.bytes + n.own.bytes
+			n = n.right
+		}
+	}
+	return -1
+}
+
+// split splits a treap into its first k words and the rest
+func split(n *node, k int) (*node, *node) {
+	if n == nil {
+		return nil, nil
+	}
+	if size(n.left) >= k {
+		left, right := split(n.left, k)
+		n.left = right
+		n.update()
+		return left, n
+	}
+	left, right := split(n.right, k-size(n.left)-1)
+	n.right = left
+	n.update()
+	return n, right
+}
+
+func merge(left, right *node) *node {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+	if left.priority > right.priority {
+		left.right = merge(left.right, right)
+		left.update()
+		return left
+	}
+	right.left = merge(left, right.left)
+	right.update()
+	return right
+}
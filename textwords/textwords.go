@@ -3,6 +3,8 @@ package textwords
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"unicode"
 )
@@ -10,14 +12,24 @@ import (
 /*
 represents a word in a block of text
 W is the word, the first letter of the word is at index-S
-in the block of text and the last letter at index-E
+in the block of text as it was read and the last letter at index-E;
+Offset and Position find a word in the text as it is after edits.
+Dirty marks words that were added or edited, or next to a deleted word.
+Raw is the word as written in markup, with any character references
+and inline tags, and is empty for plain text. OCR is what an OCR engine
+recorded about a word read from hOCR or ALTO, and page the page of a
+scanned book a plain text word is on, 0 if not known
 */
 type WordLoc struct {
-	W   string
-	s   int
-	e   int
-	lws string
-	rws string
+	W     string
+	s     int
+	e     int
+	lws   string
+	rws   string
+	dirty bool
+	raw   string
+	ocr   *OCR
+	page  int
 }
 
 /*
@@ -26,27 +38,74 @@ allows for iterating over and performing add, delete, edit
 operations on the words of the text as an array of strings, while
 maintaining the proper location of the words in the text
 
-T is the text, Ws is an array of the words of the text, mapped to
-wordLocs, and Offset is used to preserve the actual word indexes in the text
-through various modifications to the text
+T is the text as it was read, and Ws the words of the text, mapped to
+wordLocs, in a rope which keeps track of where each word is in the text
+through various modifications to the text. Tail is any whitespace after the
+last word, and Format is how the file the text was read from was stored.
+Markup is set for HTML, XHTML, EPUB, hOCR and ALTO, whose whitespace holds
+the markup between words, alto for ALTO, whose words are attributes of its
+String elements, and epub holds the archive an EPUB was read from.
+Gutenberg is set for plain text read with UseGutenbergMarkup
 */
 type TextWords struct {
-	t      string
-	ws     []WordLoc
-	offset int
+	t         string
+	ws        *rope
+	tail      string
+	format    Format
+	markup    bool
+	alto      bool
+	epub      *epub
+	gutenberg bool
 }
 
 /*
-Create TextWords from content of a file, presumably a plain-txt file
+Create TextWords from content of a file: plain text, or HTML, XHTML,
+EPUB, hOCR or ALTO going by the file's extension. The file's encoding, byte order mark
+and line endings are detected, and the text is held as UTF-8 with LF line
+endings
 */
 func FromFile(filename string) (*TextWords, error) {
 	// read file
-	if b, err := os.ReadFile(filename); err != nil {
+	b, err := os.ReadFile(filename)
+	if err != nil {
 		return &TextWords{}, err
-	} else {
-		return new(string(b)), nil
 	}
 
+	return FromBytes(b, filename)
+}
+
+/*
+Create TextWords from the content of a file named filename, read the same
+way as FromFile, for content that hasn't been written to the file yet
+*/
+func FromBytes(b []byte, filename string) (*TextWords, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".epub":
+		return FromEPUB(b)
+	case ".hocr", ".html", ".htm", ".xhtml":
+		format := DetectFormat(b)
+		txt := Decode(b, format)
+		tw := FromHTML(txt)
+		if strings.Contains(txt, "ocrx_word") {
+			tw = FromHOCR(txt)
+		}
+		tw.format = format
+		return tw, nil
+	case ".alto", ".xml":
+		format := DetectFormat(b)
+		tw, err := FromALTO(Decode(b, format))
+		if err != nil {
+			return tw, err
+		}
+		tw.format = format
+		return tw, nil
+	default:
+		format := DetectFormat(b)
+		tw := new(Decode(b, format))
+		tw.format = format
+		tw.numberPages(strings.HasSuffix(strings.ToLower(filename), "_djvu.txt"))
+		return tw, nil
+	}
 }
 
 /*
@@ -57,42 +116,98 @@ func FromString(txt string) *TextWords {
 }
 
 func new(txt string) *TextWords {
-	wls := parsewordLocs(txt)
+	wls, tail := parsewordLocs(txt)
 	return &TextWords{
-		txt,
-		wls,
-		0,
+		t:    txt,
+		ws:   newRope(wls),
+		tail: tail,
+	}
+}
+
+/*
+Format returns how the file the text was read from was stored; UTF-8
+with LF line endings for text that wasn't read from a file
+*/
+func (tw *TextWords) Format() Format {
+	return tw.format
+}
+
+/*
+Bytes returns the text as it should be written to a file, in the same
+format as the file it was read from
+*/
+func (tw *TextWords) Bytes() ([]byte, error) {
+	if tw.epub != nil {
+		return tw.epub.write(tw.Text())
 	}
+	return Encode(tw.Text(), tw.format)
 }
 
 func (tw *TextWords) Insert(w WordLoc, at int) {
-	if at >= len(tw.ws) {
-		tw.ws = append(tw.ws, w)
+	w.dirty = true
+	w.raw = ""
+	if at < tw.Len() && tw.hasMarkup(tw.ws.get(at).lws) {
+		//	keep the markup before the word the new one goes in front of,
+		//	putting the new word after it
+		next := tw.ws.at(at)
+		w.lws = next.lws
+		next.lws = " "
+		tw.ws.insert(at, w)
+	} else if at >= tw.Len() {
+		tw.ws.insert(tw.Len(), w)
 	} else if at == 0 {
-		tw.ws = append([]WordLoc{w}, tw.ws...)
+		tw.ws.insert(0, w)
 	} else {
-		tw.ws[at-1].rws = ""
-		tw.ws[at].lws = w.rws
+		tw.ws.at(at - 1).rws = ""
+		tw.ws.at(at).lws = w.rws
 
-		tw.ws = append(tw.ws[0:at], append([]WordLoc{w}, tw.ws[at:]...)...)
+		tw.ws.insert(at, w)
 	}
 }
 
+/*
+Edit replaces the word at index at by the word of newwl, keeping the
+whitespace and any markup around the word being replaced. This is so
+for plain text too: newwl is usually a word of the other file, and its
+whitespace belongs to that file's line breaks, not this one's
+*/
 func (tw *TextWords) Edit(at int, newwl WordLoc) {
-	//	TODO: update offset?
-	tw.ws[at] = newwl
+	old := tw.ws.get(at)
+	newwl.lws = old.lws
+	newwl.rws = old.rws
+	newwl.raw = old.raw
+	newwl.ocr = old.ocr
+	newwl.page = old.page
+	newwl.dirty = true
+	*tw.ws.at(at) = newwl
 }
 
 func (tw *TextWords) Delete(at int) {
+	if tw.markup {
+		tw.deleteMarkup(at)
+		return
+	}
+
+	if tw.gutenberg {
+		tw.keepMarkers(at)
+	}
+
 	if at == 0 {
-		tw.ws = tw.ws[1:]
-	} else if at == len(tw.ws)-1 {
-		tw.ws = tw.ws[0:at]
+		tw.ws.remove(0)
+		if tw.Len() > 0 {
+			tw.ws.at(0).dirty = true
+		}
+	} else if at == tw.Len()-1 {
+		tw.ws.remove(at)
+		tw.ws.at(at - 1).dirty = true
 	} else {
-		lw := &tw.ws[at-1]
-		rw := &tw.ws[at+1]
+		tw.ws.at(at + 1).dirty = true
 
-		if lw.rws != " " || rw.lws != " " { //	if the word on left or right of deleted word has significant wsp against deleted word
+		lw := tw.ws.at(at - 1)
+		rw := tw.ws.at(at + 1)
+
+		if lw.rws == "" { //	the deleted word was punctuation attached to the word on its left, so the word on its right keeps its own wsp
+		} else if lw.rws != " " || rw.lws != " " { //	if the word on left or right of deleted word has significant wsp against deleted word
 			if lw.rws != " " && rw.lws != " " { //	check if both have significant wsp
 				rw.lws = lw.rws + rw.lws //	if so, ensure it's all retained by setting as rw.lws; this is what will be applied at text generation
 			} else if lw.rws != " " { //	else if it's the lw that has significant space
@@ -100,12 +215,89 @@ func (tw *TextWords) Delete(at int) {
 			}
 		}
 
-		tw.ws = append(tw.ws[0:at], tw.ws[at+1:]...)
+		tw.ws.remove(at)
+	}
+}
+
+// hasMarkup tells if the whitespace before a word holds markup
+func (tw *TextWords) hasMarkup(lws string) bool {
+	if tw.gutenberg {
+		return strings.ContainsAny(lws, "[]")
+	}
+	return tw.markup && strings.Contains(lws, "<")
+}
+
+// keepMarkers moves the italic and bold markers of a word about to be deleted onto the words
+// either side of it, unless the word was marked on its own
+func (tw *TextWords) keepMarkers(at int) {
+	lead, trail := markers(tw.ws.get(at).raw)
+	if lead == reverse(trail) {
+		return
+	}
+
+	if lead != "" && at+1 < tw.Len() {
+		next := tw.ws.at(at + 1)
+		next.raw = lead + tw.wordText(*next)
+		next.dirty = true
+	}
+	if trail != "" && at > 0 {
+		prev := tw.ws.at(at - 1)
+		prev.raw = tw.wordText(*prev) + trail
+		prev.dirty = true
+	}
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	slices.Reverse(r)
+	return string(r)
+}
+
+// deleteMarkup deletes the word at index at, keeping the markup before and inside it
+func (tw *TextWords) deleteMarkup(at int) {
+	deleted := tw.ws.get(at)
+	kept := deleted.lws + tagsOf(deleted.raw)
+
+	//	an ALTO word's raw markup is its String element, which goes with it, as does
+	//	the space before it unless that also holds the start of a line or block
+	if tw.alto {
+		kept = deleted.lws
+		if altoSpace.MatchString(kept) {
+			kept = ""
+		}
+	}
+
+	tw.ws.remove(at)
+
+	if !strings.Contains(kept, "<") {
+		kept = ""
+	}
+
+	//	a word starting an element or line takes the element's markup, so drop
+	//	the space that separated it from the next word
+	if at < tw.Len() {
+		next := tw.ws.at(at)
+		if tw.alto && kept != "" && altoSpace.MatchString(next.lws) {
+			next.lws = ""
+		} else if !tw.alto && strings.HasSuffix(deleted.lws, ">") && tagsOf(deleted.raw) == "" && !strings.Contains(next.lws, "<") {
+			next.lws = ""
+		}
+
+		next.lws = kept + next.lws
+		next.dirty = true
+		if at > 0 {
+			tw.ws.at(at - 1).rws = next.lws
+		}
+	} else {
+		tw.tail = kept + tw.tail
+		if at > 0 {
+			tw.ws.at(at - 1).dirty = true
+		}
 	}
 }
 
 func (tw *TextWords) GetWord(at int) WordLoc {
-	return tw.ws[at]
+	return tw.ws.get(at)
 }
 
 func (tw *TextWords) SurroundingText(at, size int) string {
@@ -126,12 +318,31 @@ func (tw *TextWords) SurroundingText(at, size int) string {
 	return strings.TrimSpace(str)
 }
 
+/*
+Excerpt is the words from index from up to but not including to,
+separated by single spaces, for showing a passage of the text
+*/
+func (tw *TextWords) Excerpt(from, to int) string {
+	from = max(from, 0)
+	return tw.getFlattenedString(from, to-from)
+}
+
 func (tw *TextWords) Text() string {
-	return tw.getText(0, len(tw.ws))
+	return tw.getText(0, tw.Len()) + tw.tail
 }
 
 func (tw *TextWords) Len() int {
-	return len(tw.ws)
+	return tw.ws.len()
+}
+
+/*
+Snapshot is a copy of the words as they are now, for reading in another
+goroutine while the text goes on being edited
+*/
+func (tw *TextWords) Snapshot() *TextWords {
+	snapshot := *tw
+	snapshot.ws = newRope(tw.ws.slice())
+	return &snapshot
 }
 
 func (tw *TextWords) getText(from, size int) string {
@@ -147,10 +358,10 @@ func (tw *TextWords) getText(from, size int) string {
 		to = tw.Len()
 	}
 
-	for _, wloc := range tw.ws[from:to] {
+	tw.ws.read(from, to, func(_ int, wloc WordLoc) {
 		txt.WriteString(wloc.lws)
-		txt.WriteString(wloc.W)
-	}
+		txt.WriteString(tw.wordText(wloc))
+	})
 
 	return txt.String()
 }
@@ -167,15 +378,18 @@ func (tw *TextWords) getFlattenedString(from, size int) string {
 		to = tw.Len()
 	}
 
-	for _, wloc := range tw.ws[from:to] {
+	//	punctuation split off a word stays attached to it
+	tw.ws.read(from, to, func(_ int, wloc WordLoc) {
+		if wloc.lws != "" {
+			txt.WriteString(" ")
+		}
 		txt.WriteString(wloc.W)
-		txt.WriteString(" ")
-	}
+	})
 
 	return strings.TrimSpace(txt.String())
 }
 
-func parsewordLocs(txt string) []WordLoc {
+func parsewordLocs(txt string) ([]WordLoc, string) {
 	wls := []WordLoc{}
 	o := false
 
@@ -212,9 +426,6 @@ func parsewordLocs(txt string) []WordLoc {
 				we = i
 			} else {
 				inWord = true
-				if char == 'i' {
-					fmt.Println("")
-				}
 				w.WriteRune(char)
 				ws = i
 				we = i
@@ -241,5 +452,5 @@ func parsewordLocs(txt string) []WordLoc {
 		wspc.Reset()
 	}
 
-	return wls
+	return wls, wspc.String()
 }
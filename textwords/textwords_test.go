@@ -1,10 +1,16 @@
 package textwords
 
 import (
+	"archive/zip"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"slices"
 	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 
@@ -24,6 +30,8 @@ func TestText(t *testing.T) {
 		{"sentence with newlines","and?\n\nHow could you say that?\nReally, thats.. Pretty incredible."},
 		{"single word","wow"},
 		{"basic sentence","hello world you are looking round today"},
+		{"trailing whitespace","hello world\n\n"},
+		{"only whitespace"," \n"},
 	}
 
 	for _, tt := range tests {
@@ -95,7 +103,7 @@ func TestFromStringEndWord(t *testing.T) {
 		testname := tt.name
 		t.Run(testname, func(t *testing.T) {
 			txtWs := FromString(tt.text)
-			res := txtWs.GetWord(len(txtWs.ws)-1)
+			res := txtWs.GetWord(txtWs.Len()-1)
 
 			if res.W != tt.want.W {
 				t.Errorf("\ngot:\n'%s' word\nwant:\n'%s' word",res.W,tt.want.W)
@@ -153,6 +161,20 @@ func TestAdd(t *testing.T) {
 		})
 	}
 }
+func TestEdit(t *testing.T) {
+	txtWs := FromString("Sing, goddess, the wrath\nof Achillcs Peleus' son,\n")
+	source := FromString("Sing, goddess, the wrath of\nAchilles Peleus' son,\n")
+
+	txtWs.Edit(5, source.GetWord(5))
+	want := "Sing, goddess, the wrath\nof Achilles Peleus' son,\n"
+	if got := txtWs.Text(); got != want {
+		t.Errorf("\ngot:  '%s'\nwant: '%s'", got, want)
+	}
+	if !txtWs.GetWord(5).dirty {
+		t.Errorf("edited word not marked for rewrapping")
+	}
+}
+
 func TestDelete(t *testing.T) {
 	var tests = []struct {
 		at int
@@ -260,4 +282,805 @@ func TestSurroundingText(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestFormat(t *testing.T) {
+	var tests = []struct {
+		name   string
+		file   []byte
+		text   string
+		format Format
+	}{
+		{"utf-8", []byte("Sing, O goddess, the anger of Achilles\n"), "Sing, O goddess, the anger of Achilles\n", Format{UTF8, false, false}},
+		{"utf-8 with bom and crlf", []byte("\xEF\xBB\xBFSing, O goddess\r\nthe anger\r\n"), "Sing, O goddess\nthe anger\n", Format{UTF8, true, true}},
+		{"iso-8859-1", []byte("the r\xF4le of Ach\xE6ans\r\n"), "the rôle of Achæans\n", Format{ISO88591, false, true}},
+		{"windows-1252", []byte("\x93Sing,\x94 said the Muse \x97 caf\xE9\n"), "“Sing,” said the Muse — café\n", Format{Windows1252, false, false}},
+		{"mixed line endings", []byte("Sing\r\nO goddess\n"), "Sing\r\nO goddess\n", Format{UTF8, false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := path.Join(t.TempDir(), "text.txt")
+			os.WriteFile(filename, tt.file, 0644)
+
+			txtWs, err := FromFile(filename)
+			if err != nil {
+				t.Fatalf("got error making TextWords from file: %v", err)
+			}
+
+			if txtWs.Format() != tt.format {
+				t.Errorf("got format %v, want %v", txtWs.Format(), tt.format)
+			}
+
+			if txtWs.Text() != tt.text {
+				t.Errorf("\ngot:  %q\nwant: %q", txtWs.Text(), tt.text)
+			}
+
+			b, err := txtWs.Bytes()
+			if err != nil {
+				t.Fatalf("got error writing TextWords: %v", err)
+			}
+			if string(b) != string(tt.file) {
+				t.Errorf("\ngot:  %q\nwant: %q", b, tt.file)
+			}
+
+			for at := 0; at < txtWs.Len(); at++ {
+				want, _ := Encode(txtWs.GetWord(at).W, Format{Encoding: tt.format.Encoding})
+				if got := b[txtWs.FileOffset(at):txtWs.FileEnd(at)]; string(got) != string(want) {
+					t.Errorf("word %d at %d-%d of the file is %q, want %q", at, txtWs.FileOffset(at), txtWs.FileEnd(at), got, want)
+				}
+			}
+		})
+	}
+
+	if _, err := Encode("the Muse — café", Format{Encoding: ISO88591}); err == nil {
+		t.Errorf("expected error writing '—' as ISO-8859-1")
+	}
+}
+
+func TestGutenbergBody(t *testing.T) {
+	var tests = []struct {
+		name  string
+		text  string
+		body  string
+		found bool
+	}{
+		{
+			"markers",
+			"The Project Gutenberg eBook of The Iliad\n\n*** START OF THE PROJECT GUTENBERG EBOOK THE ILIAD ***\n\nBOOK I.\n\nSing, goddess, the wrath\n\n*** END OF THE PROJECT GUTENBERG EBOOK THE ILIAD ***\n\nUpdated editions will replace the previous one\n",
+			"BOOK I. Sing, goddess, the wrath",
+			true,
+		},
+		{
+			"older markers",
+			"Produced by volunteers\n\n***START OF THIS PROJECT GUTENBERG EBOOK ILIAD***\nSing, goddess\n***END OF THIS PROJECT GUTENBERG EBOOK ILIAD***",
+			"Sing, goddess",
+			true,
+		},
+		{
+			"marker text inside a line",
+			"we read *** START OF THE PROJECT GUTENBERG EBOOK in the header\nSing, goddess",
+			"we read *** START OF THE PROJECT GUTENBERG EBOOK in the header Sing, goddess",
+			false,
+		},
+		{"no markers", "Sing, goddess, the wrath", "Sing, goddess, the wrath", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txtWs := FromString(tt.text)
+			start, end, found := txtWs.GutenbergBody()
+
+			if found != tt.found {
+				t.Errorf("got found %v, want %v", found, tt.found)
+			}
+
+			if body := txtWs.getFlattenedString(start, end-start); body != tt.body {
+				t.Errorf("\ngot:  '%s'\nwant: '%s'", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	prose := "Sing, goddess, the wrath of Achilles Peleus' son, the ruinous wrath\n" +
+		"that brought on the Achaians woes innumerable, and hurled down into\n" +
+		"Hades many strong souls of heroes, and gave their bodies to be a prey\n" +
+		"to dogs and all winged fowls.\n\n" +
+		"Who then among the gods set the twain at strife and variance? Even the\n" +
+		"son of Leto and of Zeus.\n"
+
+	var tests = []struct {
+		name string
+		text string
+		edit func(tw *TextWords)
+		want string
+	}{
+		{
+			"untouched",
+			prose,
+			func(tw *TextWords) {},
+			prose,
+		},
+		{
+			"insert reflows until back in step",
+			prose,
+			func(tw *TextWords) { tw.Insert(WordLoc{W: "great", lws: " ", rws: " "}, 3) },
+			"Sing, goddess, the great wrath of Achilles Peleus' son, the ruinous\n" +
+				"wrath that brought on the Achaians woes innumerable, and hurled down\n" +
+				"into Hades many strong souls of heroes, and gave their bodies to be a\n" +
+				"prey to dogs and all winged fowls.\n\n" +
+				"Who then among the gods set the twain at strife and variance? Even the\n" +
+				"son of Leto and of Zeus.\n",
+		},
+		{
+			"edit that still fits",
+			prose,
+			func(tw *TextWords) { tw.Edit(2, WordLoc{W: "then", lws: " ", rws: " "}) },
+			strings.Replace(prose, "goddess, the wrath", "goddess, then wrath", 1),
+		},
+		{
+			"poetry",
+			"Sing, O Muse, of the wrath\nthat brought woes\nupon the Achaeans\n",
+			func(tw *TextWords) {
+				tw.Insert(WordLoc{W: "countless and innumerable and many and grievous and bitter", lws: " ", rws: " "}, 8)
+			},
+			"Sing, O Muse, of the wrath\nthat brought countless and innumerable and many and grievous and bitter woes\nupon the Achaeans\n",
+		},
+		{
+			"indented",
+			"    Sing, O Muse, of the wrath that brought on the Achaeans woes innumerable\n    and hurled down\n",
+			func(tw *TextWords) { tw.Insert(WordLoc{W: "countless", lws: " ", rws: " "}, 10) },
+			"    Sing, O Muse, of the wrath that brought on the countless Achaeans woes innumerable\n    and hurled down\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txtWs := FromString(tt.text)
+			tt.edit(txtWs)
+			txtWs.Rewrap(70, 0, txtWs.Len())
+
+			if res := txtWs.Text(); res != tt.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", res, tt.want)
+			}
+		})
+	}
+
+	t.Run("header and footer", func(t *testing.T) {
+		header := "The Project Gutenberg eBook of The Iliad, by Homer, translated by Samuel Butler, and made available\n\n" +
+			"*** START OF THE PROJECT GUTENBERG EBOOK THE ILIAD OF HOMER TRANSLATED BY SAMUEL BUTLER ***\n\n"
+		footer := "\n\n*** END OF THE PROJECT GUTENBERG EBOOK THE ILIAD OF HOMER TRANSLATED BY SAMUEL BUTLER ***\n"
+		txtWs := FromString(header + prose + footer)
+
+		//	deleting the last word of the body marks the first word of the footer changed
+		start, end, _ := txtWs.GutenbergBody()
+		txtWs.Delete(end - 1)
+		txtWs.Insert(WordLoc{W: "great", lws: " ", rws: " "}, start+3)
+		start, end, _ = txtWs.GutenbergBody()
+		txtWs.Rewrap(70, start, end)
+
+		want := header +
+			"Sing, goddess, the great wrath of Achilles Peleus' son, the ruinous\n" +
+			"wrath that brought on the Achaians woes innumerable, and hurled down\n" +
+			"into Hades many strong souls of heroes, and gave their bodies to be a\n" +
+			"prey to dogs and all winged fowls.\n\n" +
+			"Who then among the gods set the twain at strife and variance? Even the\n" +
+			"son of Leto and of\n" + footer
+		if res := txtWs.Text(); res != want {
+			t.Errorf("\ngot:\n%s\nwant:\n%s", res, want)
+		}
+	})
+}
+
+func TestHTML(t *testing.T) {
+	doc := "<html><head><title>The Iliad</title><style>p { margin: 0 }</style></head>\n" +
+		"<body><h1>BOOK I.</h1><p>Sing, goddess, the <i>wrath</i> of Achil<i>les</i> Peleus&#8217; son,\n" +
+		"the ruin&shy;ous wrath&nbsp;that brought on the Achaians woes &amp; more</p></body></html>\n"
+
+	var tests = []struct {
+		name string
+		edit func(tw *TextWords)
+		want string
+	}{
+		{"unchanged", func(tw *TextWords) {}, doc},
+		{
+			"edit keeps tags inside the word",
+			func(tw *TextWords) { tw.Edit(7, WordLoc{W: "Achilleus"}) },
+			strings.Replace(doc, "Achil<i>les</i>", "Achil<i>leus</i>", 1),
+		},
+		{
+			"edit escapes",
+			func(tw *TextWords) { tw.Edit(19, WordLoc{W: "<and>"}) },
+			strings.Replace(doc, "&amp; more", "&lt;and&gt; more", 1),
+		},
+		{
+			"delete keeps tags",
+			func(tw *TextWords) { tw.Delete(5) },
+			strings.Replace(doc, "the <i>wrath</i> of", "the <i></i> of", 1),
+		},
+		{
+			"delete first word of a paragraph",
+			func(tw *TextWords) { tw.Delete(2) },
+			strings.Replace(doc, "<p>Sing, goddess", "<p>goddess", 1),
+		},
+		{
+			"insert after markup",
+			func(tw *TextWords) { tw.Insert(WordLoc{W: "O", lws: " ", rws: " "}, 3) },
+			strings.Replace(doc, "Sing, goddess", "Sing, O goddess", 1),
+		},
+		{
+			"insert at a paragraph's start",
+			func(tw *TextWords) { tw.Insert(WordLoc{W: "“Sing", lws: " ", rws: " "}, 2) },
+			strings.Replace(doc, "<p>Sing,", "<p>“Sing Sing,", 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txtWs := FromHTML(doc)
+			tt.edit(txtWs)
+
+			if res := txtWs.Text(); res != tt.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", res, tt.want)
+			}
+		})
+	}
+
+	want := "BOOK I. Sing, goddess, the wrath of Achilles Peleus’ son, the ruin­ous wrath that brought on the Achaians woes & more"
+	if words := FromHTML(doc).getFlattenedString(0, 100); words != want {
+		t.Errorf("\ngot words:  '%s'\nwant words: '%s'", words, want)
+	}
+}
+
+func TestEPUB(t *testing.T) {
+	files := []struct{ name, content string }{
+		{"mimetype", "application/epub+zip"},
+		{"META-INF/container.xml", `<?xml version="1.0"?><container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container"><rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles></container>`},
+		{"OEBPS/content.opf", `<?xml version="1.0"?><package xmlns="http://www.idpf.org/2007/opf" version="3.0"><manifest>` +
+			`<item id="c2" href="text/book%202.xhtml" media-type="application/xhtml+xml"/><item id="c1" href="text/book1.xhtml" media-type="application/xhtml+xml"/>` +
+			`<item id="css" href="style.css" media-type="text/css"/></manifest><spine><itemref idref="c1"/><itemref idref="c2"/></spine></package>`},
+		{"OEBPS/style.css", "p { margin: 0 }"},
+		{"OEBPS/text/book1.xhtml", "<html><body><p>Sing, goddess, the wrath</p></body></html>"},
+		{"OEBPS/text/book 2.xhtml", "<html><body><p>Now the other gods</p></body></html>"},
+	}
+
+	archive := bytes.Buffer{}
+	w := zip.NewWriter(&archive)
+	for _, f := range files {
+		fw, _ := w.Create(f.name)
+		fw.Write([]byte(f.content))
+	}
+	w.Close()
+
+	filename := path.Join(t.TempDir(), "iliad.epub")
+	os.WriteFile(filename, archive.Bytes(), 0644)
+
+	txtWs, err := FromFile(filename)
+	if err != nil {
+		t.Fatalf("got error making TextWords from EPUB: %v", err)
+	}
+
+	if words := txtWs.getFlattenedString(0, txtWs.Len()); words != "Sing, goddess, the wrath Now the other gods" {
+		t.Fatalf("got words '%s'", words)
+	}
+
+	txtWs.Edit(5, WordLoc{W: "then"})
+
+	b, err := txtWs.Bytes()
+	if err != nil {
+		t.Fatalf("got error writing EPUB: %v", err)
+	}
+
+	written, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("written EPUB can't be read: %v", err)
+	}
+
+	for n, f := range written.File {
+		want := files[n].content
+		if f.Name == "OEBPS/text/book 2.xhtml" {
+			want = "<html><body><p>Now then other gods</p></body></html>"
+		}
+
+		r, _ := f.Open()
+		content, _ := io.ReadAll(r)
+		if f.Name != files[n].name || string(content) != want {
+			t.Errorf("got %s: %s\nwant %s: %s", f.Name, content, files[n].name, want)
+		}
+	}
+}
+
+func TestHOCR(t *testing.T) {
+	doc := "<html><body><div class='ocr_page' title='bbox 0 0 2000 3000'>\n" +
+		"<span class='ocr_line' title='bbox 100 100 900 140'><span class='ocrx_word' title='bbox 100 100 180 140; x_wconf 96'>Sing,</span> " +
+		"<span class='ocrx_word' title='bbox 190 100 320 140; x_wconf 41'>goddcss,</span></span>\n" +
+		"<span class='ocr_line' title='bbox 100 150 900 190'><span class='ocrx_word' title='bbox 100 150 160 190; x_wconf 90'>the</span></span>\n" +
+		"</div><div class='ocr_page'><span class='ocr_line'><span class='ocrx_word' title='bbox 5 6 7 8'>wrath</span></span></div></body></html>"
+
+	txtWs := FromHOCR(doc)
+
+	want := []OCR{
+		{0.96, 1, 1, [4]int{100, 100, 180, 140}},
+		{0.41, 1, 1, [4]int{190, 100, 320, 140}},
+		{0.90, 1, 2, [4]int{100, 150, 160, 190}},
+		{-1, 2, 1, [4]int{5, 6, 7, 8}},
+	}
+	if txtWs.Len() != len(want) {
+		t.Fatalf("got %d words, want %d", txtWs.Len(), len(want))
+	}
+	for at, w := range want {
+		if ocr := txtWs.GetWord(at).OCR(); ocr == nil || *ocr != w {
+			t.Errorf("word %d: got %+v, want %+v", at, ocr, w)
+		}
+	}
+
+	txtWs.Edit(1, WordLoc{W: "goddess,"})
+	if res := txtWs.Text(); res != strings.Replace(doc, "goddcss,", "goddess,", 1) {
+		t.Errorf("edit wasn't written into the hOCR:\n%s", res)
+	}
+	if ocr := txtWs.GetWord(1).OCR(); ocr == nil || ocr.Confidence != 0.41 {
+		t.Errorf("edit lost the word's OCR: %+v", ocr)
+	}
+}
+
+func TestALTO(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<alto xmlns="http://www.loc.gov/standards/alto/ns-v3#"><Layout><Page ID="p1"><PrintSpace><TextBlock>
+<TextLine><String CONTENT="Sing," WC="0.97" HPOS="100" VPOS="100" WIDTH="80" HEIGHT="40"/><SP/><String CONTENT="goddcss," WC="0.38" HPOS="190" VPOS="100" WIDTH="130" HEIGHT="40"/></TextLine>
+<TextLine><String CONTENT="the" WC="0.9"/><SP/><String CONTENT='"wrath"'/></TextLine>
+</TextBlock></PrintSpace></Page></Layout></alto>
+`
+	txtWs, err := FromALTO(doc)
+	if err != nil {
+		t.Fatalf("got error making TextWords from ALTO: %v", err)
+	}
+
+	if words := txtWs.getFlattenedString(0, txtWs.Len()); words != `Sing, goddcss, the "wrath"` {
+		t.Fatalf("got words '%s'", words)
+	}
+	if ocr := txtWs.GetWord(1).OCR(); ocr == nil || *ocr != (OCR{0.38, 1, 1, [4]int{190, 100, 320, 140}}) {
+		t.Errorf("got %+v", ocr)
+	}
+	if ocr := txtWs.GetWord(2).OCR(); ocr == nil || ocr.Line != 2 {
+		t.Errorf("got %+v", ocr)
+	}
+
+	if txtWs.Text() != doc {
+		t.Errorf("ALTO didn't round trip:\n%s", txtWs.Text())
+	}
+
+	txtWs.Edit(1, WordLoc{W: "goddess,"})
+	txtWs.Edit(3, WordLoc{W: "wrath"})
+	txtWs.Delete(2)
+
+	want := strings.Replace(doc, `CONTENT="goddcss,"`, `CONTENT="goddess,"`, 1)
+	want = strings.Replace(want, `<String CONTENT="the" WC="0.9"/><SP/><String CONTENT='"wrath"'/>`, `<String CONTENT='wrath'/>`, 1)
+	if res := txtWs.Text(); res != want {
+		t.Errorf("\ngot:\n%s\nwant:\n%s", res, want)
+	}
+}
+
+func TestNotALTO(t *testing.T) {
+	var tests = []struct {
+		name string
+		doc  string
+		err  bool
+	}{
+		{"other XML", `<?xml version="1.0"?>\n<catalog><book id="1"><title>The Iliad</title></book></catalog>\n`, true},
+		{"empty ALTO", `<?xml version="1.0"?>\n<alto xmlns="http://www.loc.gov/standards/alto/ns-v3#"><Layout/></alto>\n`, false},
+		{"String elements without alto root", `<Page><TextLine><String CONTENT="Sing"/></TextLine></Page>`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := path.Join(t.TempDir(), "page.xml")
+			os.WriteFile(filename, []byte(tt.doc), 0644)
+
+			_, err := FromFile(filename)
+			if (err != nil) != tt.err {
+				t.Errorf("got error %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestPages(t *testing.T) {
+	var tests = []struct {
+		name  string
+		file  string
+		text  string
+		pages []int
+	}{
+		{"form feeds", "iliad.txt", "Sing, goddess\f\nthe wrath\n\f\fof Achilles", []int{1, 1, 2, 2, 4, 4}},
+		{"page numbers", "iliad_djvu.txt", "preface\n\n3\nSing, goddess\n\n4\n\nthe wrath 5 of\n\n5\nAchilles", []int{2, 3, 3, 3, 4, 4, 4, 4, 4, 5, 5}},
+		{"too few page numbers", "iliad_djvu.txt", "Sing\n3\ngoddess\n4\nthe wrath", []int{0, 0, 0, 0, 0, 0}},
+		{"numbers outside djvu.txt", "iliad.txt", "1\nSing\n2\ngoddess\n3\nthe wrath", []int{0, 0, 0, 0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := path.Join(t.TempDir(), tt.file)
+			os.WriteFile(filename, []byte(tt.text), 0644)
+
+			txtWs, err := FromFile(filename)
+			if err != nil {
+				t.Fatalf("got error making TextWords from file: %v", err)
+			}
+
+			pages := []int{}
+			for at := 0; at < txtWs.Len(); at++ {
+				pages = append(pages, txtWs.GetWord(at).Page())
+			}
+			if fmt.Sprint(pages) != fmt.Sprint(tt.pages) {
+				t.Errorf("got pages %v, want %v", pages, tt.pages)
+			}
+		})
+	}
+}
+
+func TestGutenbergMarkup(t *testing.T) {
+	text := "Sing, _goddess_, the =wrath= of _Achilles\nPeleus'_ son.\n\n[Illustration: The shield of Achilles]\n\nThe ruinous[Footnote 3: Book I.] wrath [**sic] that brought\n"
+
+	var tests = []struct {
+		name  string
+		skip  bool
+		words string
+	}{
+		{"compare notes", false, "Sing, goddess, the wrath of Achilles Peleus' son. The shield of Achilles The ruinous Book I. wrath sic that brought"},
+		{"skip notes", true, "Sing, goddess, the wrath of Achilles Peleus' son. The ruinous wrath that brought"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txtWs := FromString(text)
+			txtWs.UseGutenbergMarkup(tt.skip)
+
+			if words := txtWs.getFlattenedString(0, txtWs.Len()); words != tt.words {
+				t.Errorf("\ngot:  '%s'\nwant: '%s'", words, tt.words)
+			}
+			if txtWs.Text() != text {
+				t.Errorf("text changed to '%s'", txtWs.Text())
+			}
+		})
+	}
+
+	t.Run("edit", func(t *testing.T) {
+		txtWs := FromString(text)
+		txtWs.UseGutenbergMarkup(false)
+		txtWs.Edit(1, WordLoc{W: "Goddess;"})
+		txtWs.Edit(13, WordLoc{W: "shield"})
+		want := "Sing, _Goddess_; the =wrath= of _Achilles\nPeleus'_ son.\n\n[Illustration: The shield of Achilles]"
+		if got := txtWs.Text(); !strings.HasPrefix(got, want) {
+			t.Errorf("\ngot:  '%s'\nwant: '%s'", got, want)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		txtWs := FromString(text)
+		txtWs.UseGutenbergMarkup(false)
+		txtWs.Delete(5)
+		txtWs.Delete(1)
+		want := "Sing, the =wrath= of\n_Peleus'_ son."
+		if got := txtWs.Text(); !strings.HasPrefix(got, want) {
+			t.Errorf("\ngot:  '%s'\nwant: '%s'", got, want)
+		}
+	})
+
+	t.Run("markers moved onto the next word", func(t *testing.T) {
+		txtWs := FromString(text)
+		txtWs.UseGutenbergMarkup(false)
+		txtWs.keepMarkers(5)
+		if next := txtWs.GetWord(6); next.raw != "_Peleus'_" || !next.dirty {
+			t.Errorf("got %#v, want the word marked and dirty for rewrapping", next)
+		}
+	})
+
+	t.Run("insert into a note", func(t *testing.T) {
+		txtWs := FromString(text)
+		txtWs.UseGutenbergMarkup(false)
+		txtWs.Insert(WordLoc{W: "Lo,", rws: " "}, 8)
+		want := "son.\n\n[Illustration: Lo, The shield"
+		if got := txtWs.Text(); !strings.Contains(got, want) {
+			t.Errorf("\ngot:  '%s'\nwant: '%s'", got, want)
+		}
+	})
+}
+
+func TestChapters(t *testing.T) {
+	edit := FromString("CONTENTS\n\nBOOK I. The Quarrel\nBOOK II. The Dream\nBOOK III. The Duel\n\nBOOK I. The Quarrel\n\nSing, goddess\n\n\nBOOK II. The Dream\n\nNow all the other gods\n\nBOOK III.\n\nNow when they were arrayed\n")
+	source := FromString("BOOK  I. \n\nSing, goddess \n\nNow all the other gods \n\nBOOK III. \n\nNow when they were arrayed \n")
+
+	titles := []string{}
+	for _, c := range edit.Chapters() {
+		titles = append(titles, c.Title+"="+c.Key)
+	}
+	want := []string{"BOOK I. The Quarrel=book 1", "BOOK II. The Dream=book 2", "BOOK III.=book 3"}
+	if !slices.Equal(titles, want) {
+		t.Errorf("\ngot:  %v\nwant: %v", titles, want)
+	}
+
+	anchors := PairChapters(edit, source)
+	wantAnchors := []Anchor{{"BOOK I. The Quarrel", 13, 0}, {"BOOK III.", 28, 9}}
+	if !slices.Equal(anchors, wantAnchors) {
+		t.Errorf("\ngot:  %v\nwant: %v", anchors, wantAnchors)
+	}
+
+	iliad, err := FromFile("test/gutenberg-iliad.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chapters := iliad.Chapters(); len(chapters) != 24 || chapters[23].Key != "book 24" {
+		t.Errorf("got %d chapters in the Iliad, want 24 books", len(chapters))
+	}
+}
+
+func TestRope(t *testing.T) {
+	model := []WordLoc{}
+	for n := 0; n < 500; n++ {
+		model = append(model, WordLoc{W: fmt.Sprint(n)})
+	}
+	r := newRope(model)
+
+	check := func(step string) {
+		if r.len() != len(model) {
+			t.Fatalf("%s: got %d words, want %d", step, r.len(), len(model))
+		}
+		for at, wl := range r.slice() {
+			if wl.W != model[at].W || r.at(at).W != model[at].W {
+				t.Fatalf("%s: got %s at %d, want %s", step, wl.W, at, model[at].W)
+			}
+		}
+	}
+	check("built")
+
+	for n := 0; n < 300; n++ {
+		at := (n * 7919) % (len(model) + 1)
+		if n%3 == 0 && at < len(model) {
+			r.remove(at)
+			model = append(model[:at], model[at+1:]...)
+		} else {
+			wl := WordLoc{W: fmt.Sprint("new", n)}
+			r.insert(at, wl)
+			model = append(model[:at], append([]WordLoc{wl}, model[at:]...)...)
+		}
+	}
+	check("after inserts and deletes")
+
+	r.at(10).W = "changed"
+	model[10].W = "changed"
+	got := []string{}
+	r.each(8, 12, func(at int, wl *WordLoc) { got = append(got, fmt.Sprint(at, wl.W)) })
+	want := []string{"8" + model[8].W, "9" + model[9].W, "10changed", "11" + model[11].W}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+/*
+Benchmarks against the Iliad, of about 150k words. Those starting Slice
+time the same edits on a slice of words, as TextWords used to hold them,
+for comparison
+*/
+func iliad(b *testing.B) *TextWords {
+	tw, err := FromFile("test/gutenberg-iliad.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return tw
+}
+
+func BenchmarkFromFile(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		iliad(b)
+	}
+}
+
+func BenchmarkInsert(b *testing.B) {
+	tw := iliad(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		tw.Insert(WordLoc{W: "word", lws: " ", rws: " "}, (n*7919)%tw.Len())
+	}
+}
+
+func BenchmarkSliceInsert(b *testing.B) {
+	wls := iliad(b).ws.slice()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		at := (n * 7919) % len(wls)
+		wls = append(wls[0:at], append([]WordLoc{{W: "word", lws: " ", rws: " "}}, wls[at:]...)...)
+	}
+}
+
+func BenchmarkDelete(b *testing.B) {
+	tw := iliad(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if tw.Len() < 2 {
+			b.StopTimer()
+			tw = iliad(b)
+			b.StartTimer()
+		}
+		tw.Delete((n * 7919) % (tw.Len() - 1))
+	}
+}
+
+func BenchmarkSliceDelete(b *testing.B) {
+	wls := iliad(b).ws.slice()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if len(wls) < 2 {
+			b.StopTimer()
+			wls = iliad(b).ws.slice()
+			b.StartTimer()
+		}
+		at := (n * 7919) % (len(wls) - 1)
+		wls = append(wls[0:at], wls[at+1:]...)
+	}
+}
+
+func BenchmarkGetWord(b *testing.B) {
+	tw := iliad(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		tw.GetWord(n % tw.Len())
+	}
+}
+
+func BenchmarkText(b *testing.B) {
+	tw := iliad(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		tw.Text()
+	}
+}
+
+func TestOffsets(t *testing.T) {
+	check := func(t *testing.T, tw *TextWords) {
+		text := tw.Text()
+		for at := 0; at < tw.Len(); at++ {
+			offset := tw.Offset(at)
+			w := tw.wordText(tw.GetWord(at))
+			if !strings.HasPrefix(text[offset:], w) {
+				t.Fatalf("word %d '%s' not at offset %d: '%s'", at, w, offset, text[offset:min(len(text), offset+20)])
+			}
+			if end := tw.End(at); text[offset:end] != w {
+				t.Fatalf("word %d '%s' ends at %d: '%s'", at, w, end, text[offset:end])
+			}
+
+			before := text[:offset]
+			line := strings.Count(before, "\n") + 1
+			col := utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:]) + 1
+			if l, c := tw.Position(at); l != line || c != col {
+				t.Fatalf("word %d '%s' at %d:%d, want %d:%d", at, w, l, c, line, col)
+			}
+
+			if got := tw.AtOffset(offset); got != at {
+				t.Fatalf("AtOffset(%d) = %d, want %d", offset, got, at)
+			}
+			if got := tw.AtPosition(line, col); got != at {
+				t.Fatalf("AtPosition(%d, %d) = %d, want %d", line, col, got, at)
+			}
+		}
+	}
+
+	t.Run("edits", func(t *testing.T) {
+		tw := FromString("Sing, goddéss, the wrath\nof Achilles Peleus' son,\n\n  the ruinous wrath that brought\n")
+		check(t, tw)
+
+		tw.Insert(WordLoc{W: "O", lws: " ", rws: " "}, 1)
+		tw.Edit(3, WordLoc{W: "wræþþ"})
+		tw.Delete(5)
+		tw.Insert(WordLoc{W: "countless", lws: " ", rws: " "}, tw.Len())
+		check(t, tw)
+
+		if line, col := tw.Position(8); line != 4 || col != 3 {
+			t.Errorf("got %d:%d for '%s', want 4:3", line, col, tw.GetWord(8).W)
+		}
+		if at := tw.AtPosition(1, 200); at != 5 {
+			t.Errorf("got %d past the end of line 1, want the first word of line 2", at)
+		}
+		if at := tw.AtPosition(9, 1); at != tw.Len() {
+			t.Errorf("got %d for a line past the end, want %d", at, tw.Len())
+		}
+	})
+
+	t.Run("markup", func(t *testing.T) {
+		tw := FromHTML("<p>Sing, <i>goddess</i>,\nthe wrath</p>\n<p>of Achil<i>les</i> Peleus&rsquo; son</p>\n")
+		tw.Edit(4, WordLoc{W: "Achillcs"})
+		tw.Delete(0)
+		check(t, tw)
+	})
+
+	t.Run("iliad", func(t *testing.T) {
+		tw, err := FromFile("test/gutenberg-iliad.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n < 200; n++ {
+			at := (n * 7919) % tw.Len()
+			switch n % 3 {
+			case 0:
+				tw.Insert(WordLoc{W: "word", lws: " ", rws: " "}, at)
+			case 1:
+				tw.Delete(at)
+			default:
+				tw.Edit(at, WordLoc{W: "edited"})
+			}
+		}
+
+		text := tw.Text()
+		for at := 0; at < tw.Len(); at += 997 {
+			if offset := tw.Offset(at); !strings.HasPrefix(text[offset:], tw.GetWord(at).W) {
+				t.Fatalf("word %d '%s' not at offset %d", at, tw.GetWord(at).W, offset)
+			}
+		}
+	})
+}
+
+func BenchmarkOffset(b *testing.B) {
+	tw := iliad(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		at := (n * 7919) % tw.Len()
+		tw.Edit(at, WordLoc{W: "edited"})
+		tw.Position(at)
+	}
+}
+
+func TestSplitPunctuation(t *testing.T) {
+	text := "“Sing, goddess,” the wrath of Peleus' son—\n\n***\n\nAchilles; don't ruinous...\n"
+
+	txtWs := FromString(text)
+	txtWs.SplitPunctuation()
+
+	want := "“Sing, goddess,” the wrath of Peleus' son— *** Achilles; don't ruinous..."
+	if words := txtWs.getFlattenedString(0, txtWs.Len()); words != want {
+		t.Errorf("\ngot:  '%s'\nwant: '%s'", words, want)
+	}
+	if txtWs.Len() != 21 || txtWs.GetWord(4).W != "," || txtWs.GetWord(13).W != "***" {
+		t.Errorf("tokens: %v", txtWs.ws.slice())
+	}
+	if txtWs.Text() != text {
+		t.Errorf("text changed to '%s'", txtWs.Text())
+	}
+
+	t.Run("edit, delete and insert punctuation", func(t *testing.T) {
+		txtWs := FromString(text)
+		txtWs.SplitPunctuation()
+		txtWs.Edit(15, WordLoc{W: ","})
+		txtWs.Delete(12)
+		txtWs.Delete(2)
+		txtWs.Insert(WordLoc{W: "!", lws: "", rws: " "}, 10)
+		want := "“Sing goddess,” the wrath of Peleus'! son\n\n***\n\nAchilles, don't ruinous...\n"
+		if got := txtWs.Text(); got != want {
+			t.Errorf("\ngot:  '%s'\nwant: '%s'", got, want)
+		}
+	})
+
+	t.Run("gutenberg markup", func(t *testing.T) {
+		text := "Sing, _goddess_, the wrath [**sic] of _Achilles_.\n"
+		txtWs := FromString(text)
+		txtWs.SplitPunctuation()
+		txtWs.UseGutenbergMarkup(true)
+		want := "Sing, goddess, the wrath of Achilles."
+		if words := txtWs.getFlattenedString(0, txtWs.Len()); words != want {
+			t.Errorf("\ngot:  '%s'\nwant: '%s'", words, want)
+		}
+		if txtWs.Text() != text {
+			t.Errorf("text changed to '%s'", txtWs.Text())
+		}
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	txtWs := FromString("Sing, goddess, the wrath of Achilles")
+	snapshot := txtWs.Snapshot()
+
+	txtWs.Edit(1, WordLoc{W: "Muse,"})
+	txtWs.Delete(0)
+
+	if words := snapshot.getFlattenedString(0, snapshot.Len()); words != "Sing, goddess, the wrath of Achilles" {
+		t.Errorf("snapshot changed with the text to '%s'", words)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -57,9 +58,63 @@ func Min(a, b int) int {
 	return b
 }
 
-// updateFile updates the file content with the specified words.
+// UpdateFile replaces the content of a file atomically: the content is written and
+// synced to a temporary file in the same directory which is then renamed over the file,
+// so a crash leaves either the old content or the new, never a partial file
 func UpdateFile(filename string, content string) error {
-	return os.WriteFile(filename, []byte(content), 0644)
+	dir := filepath.Dir(filename)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	syncDir(dir)
+	return nil
+}
+
+// syncDir makes a rename in dir durable. Not every platform can sync a directory,
+// in which case the rename is left to the OS
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// CopyFile copies the content of src to dst, replacing dst if it exists
+func CopyFile(src, dst string) error {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return UpdateFile(dst, string(content))
 }
 
 func ParseDigits(input string) ([]int, error) {